@@ -156,8 +156,7 @@ func (handler *Handler) applyPortainerLabelsToHelmAppManifest(r *http.Request, i
 		return nil, errors.Wrap(err, "unable to load user information from the database")
 	}
 
-	appLabels := kubernetes.GetHelmAppLabels(installOpts.Name, user.Username)
-	labeledManifest, err := kubernetes.AddAppLabels([]byte(manifest), appLabels)
+	labeledManifest, err := kubernetes.AddHelmAppLabels([]byte(manifest), installOpts.Name, user.Username)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to label helm release manifest")
 	}
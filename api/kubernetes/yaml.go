@@ -2,24 +2,51 @@ package kubernetes
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/pkg/errors"
+	"golang.org/x/text/unicode/norm"
 	"gopkg.in/yaml.v3"
 )
 
+// DefaultLabelPrefix is the prefix of the labels applied to all resources deployed in a kubernetes stack
+const DefaultLabelPrefix = "io.portainer.kubernetes.application"
+
+const (
+	labelSuffixStack   = "stack"
+	labelSuffixStackID = "stackid"
+	labelSuffixName    = "name"
+	labelSuffixOwner   = "owner"
+	labelSuffixKind    = "kind"
+)
+
 const (
-	labelPortainerAppStack   = "io.portainer.kubernetes.application.stack"
-	labelPortainerAppStackID = "io.portainer.kubernetes.application.stackid"
-	labelPortainerAppName    = "io.portainer.kubernetes.application.name"
-	labelPortainerAppOwner   = "io.portainer.kubernetes.application.owner"
-	labelPortainerAppKind    = "io.portainer.kubernetes.application.kind"
+	labelPortainerAppStack   = DefaultLabelPrefix + "." + labelSuffixStack
+	labelPortainerAppStackID = DefaultLabelPrefix + "." + labelSuffixStackID
+	labelPortainerAppName    = DefaultLabelPrefix + "." + labelSuffixName
+	labelPortainerAppOwner   = DefaultLabelPrefix + "." + labelSuffixOwner
+	labelPortainerAppKind    = DefaultLabelPrefix + "." + labelSuffixKind
 )
 
+// StackLabelSelector returns the label selector matching the resources of the stack with the given id, as accepted by kubectl -l
+func StackLabelSelector(stackID int) string {
+	return labelPortainerAppStackID + "=" + strconv.Itoa(stackID)
+}
+
+// StackNameSelector returns the label selector matching the resources of the stack or helm application
+// with the given name, as accepted by kubectl -l
+func StackNameSelector(name string) string {
+	return labelPortainerAppName + "=" + name
+}
+
 // KubeAppLabels are labels applied to all resources deployed in a kubernetes stack
 type KubeAppLabels struct {
 	StackID   int
@@ -28,42 +55,378 @@ type KubeAppLabels struct {
 	Kind      string
 }
 
-// convert string to valid kubernetes label by replacing invalid characters with periods
-func sanitizeLabel(value string) string {
-	re := regexp.MustCompile(`[^A-Za-z0-9\.\-\_]+`)
-	return re.ReplaceAllString(value, ".")
+// labelValueMaxLength is the maximum length of a kubernetes label value
+const labelValueMaxLength = 63
+
+var labelValueInvalidChars = regexp.MustCompile(`[^A-Za-z0-9\.\-\_]+`)
+
+// labelTransliterations holds the ASCII replacements of the common latin letters that don't decompose
+// into a base letter and diacritics
+var labelTransliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE", 'ø': "o", 'Ø': "O",
+	'ł': "l", 'Ł': "L", 'đ': "d", 'Đ': "D", 'ð': "d", 'Ð': "D", 'þ': "th", 'Þ': "TH", 'ı': "i",
+}
+
+// SanitizeLabelValue converts a string to a valid kubernetes label value. Accented latin letters are transliterated
+// to ASCII, the remaining invalid characters are replaced with periods, the value is truncated to the maximum
+// label length and non alphanumeric characters are trimmed from both ends.
+// Uppercase characters are valid in label values and are kept.
+func SanitizeLabelValue(value string) string {
+	value = trimNonAlphanumeric(labelValueInvalidChars.ReplaceAllString(transliterate(value), "."))
+
+	if len(value) > labelValueMaxLength {
+		value = trimNonAlphanumeric(value[:labelValueMaxLength])
+	}
+
+	return value
+}
+
+// transliterate replaces accented latin letters with their ASCII base letters, other characters are kept
+func transliterate(value string) string {
+	var sb strings.Builder
+	for _, r := range norm.NFD.String(value) {
+		if replacement, ok := labelTransliterations[r]; ok {
+			sb.WriteString(replacement)
+			continue
+		}
+
+		// drop the diacritics split from their base letter by the decomposition
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+func trimNonAlphanumeric(value string) string {
+	return strings.TrimFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
 }
 
 // ToMap converts KubeAppLabels to a map[string]string
 func (kal *KubeAppLabels) ToMap() map[string]string {
+	return kal.toMap(DefaultLabelPrefix)
+}
+
+// ToMapWithPrefix converts KubeAppLabels to a map[string]string, using prefix instead of DefaultLabelPrefix for the label keys.
+// It fails if the prefix results in invalid label keys, such as keys longer than 63 characters without a slash.
+func (kal *KubeAppLabels) ToMapWithPrefix(prefix string) (map[string]string, error) {
+	labels := kal.toMap(prefix)
+	if err := ValidateLabelKeys(labels); err != nil {
+		return nil, errors.Wrapf(err, "invalid label prefix '%s'", prefix)
+	}
+
+	return labels, nil
+}
+
+func (kal *KubeAppLabels) toMap(prefix string) map[string]string {
 	return map[string]string{
-		labelPortainerAppStackID: strconv.Itoa(kal.StackID),
-		labelPortainerAppStack:   kal.StackName,
-		labelPortainerAppName:    kal.StackName,
-		labelPortainerAppOwner:   sanitizeLabel(kal.Owner),
-		labelPortainerAppKind:    kal.Kind,
+		labelKey(prefix, labelSuffixStackID): strconv.Itoa(kal.StackID),
+		labelKey(prefix, labelSuffixStack):   kal.StackName,
+		labelKey(prefix, labelSuffixName):    kal.StackName,
+		labelKey(prefix, labelSuffixOwner):   SanitizeLabelValue(kal.Owner),
+		labelKey(prefix, labelSuffixKind):    kal.Kind,
+	}
+}
+
+// KubeAppLabelsFromMap reconstructs KubeAppLabels from the labels of a resource.
+// It returns an error if the stack id label is missing or not numeric, which means the resource is not part of a stack.
+// The other labels are optional and are left empty when missing.
+func KubeAppLabelsFromMap(labels map[string]string) (*KubeAppLabels, error) {
+	value, ok := labels[labelPortainerAppStackID]
+	if !ok {
+		return nil, errors.Errorf("missing '%s' label", labelPortainerAppStackID)
+	}
+
+	stackID, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid '%s' label", labelPortainerAppStackID)
 	}
+
+	return &KubeAppLabels{
+		StackID:   stackID,
+		StackName: labels[labelPortainerAppStack],
+		Owner:     labels[labelPortainerAppOwner],
+		Kind:      labels[labelPortainerAppKind],
+	}, nil
+}
+
+// GetOwner returns the owner of a Portainer deployed resource from its labels, or an empty string
+func GetOwner(labels map[string]string) string {
+	return labels[labelPortainerAppOwner]
+}
+
+// GetStackID returns the id of the stack a resource is part of from its labels.
+// It returns false if the stack id label is missing or not numeric.
+func GetStackID(labels map[string]string) (int, bool) {
+	value, ok := labels[labelPortainerAppStackID]
+	if !ok {
+		return 0, false
+	}
+
+	stackID, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return stackID, true
 }
 
 // GetHelmAppLabels returns the labels to be applied to portainer deployed helm applications
 func GetHelmAppLabels(name, owner string) map[string]string {
+	return helmAppLabels(name, owner, DefaultLabelPrefix)
+}
+
+// GetHelmAppLabelsWithPrefix returns the labels to be applied to portainer deployed helm applications,
+// using prefix instead of DefaultLabelPrefix for the label keys. It fails if the prefix results in invalid label keys.
+func GetHelmAppLabelsWithPrefix(name, owner, prefix string) (map[string]string, error) {
+	labels := helmAppLabels(name, owner, prefix)
+	if err := ValidateLabelKeys(labels); err != nil {
+		return nil, errors.Wrapf(err, "invalid label prefix '%s'", prefix)
+	}
+
+	return labels, nil
+}
+
+func helmAppLabels(name, owner, prefix string) map[string]string {
 	return map[string]string{
-		labelPortainerAppName:  name,
-		labelPortainerAppOwner: sanitizeLabel(owner),
+		labelKey(prefix, labelSuffixName):  name,
+		labelKey(prefix, labelSuffixOwner): SanitizeLabelValue(owner),
+	}
+}
+
+// labelKey joins a label prefix and suffix with a period, or appends the suffix as the name of the key when the prefix
+// ends with a slash. The key is not sanitized, invalid keys are rejected by ValidateLabelKeys instead of being rewritten
+// since the rewritten keys could collide.
+func labelKey(prefix, suffix string) string {
+	if strings.HasSuffix(prefix, "/") {
+		return prefix + suffix
 	}
+
+	return strings.TrimSuffix(prefix, ".") + "." + suffix
 }
 
 // AddAppLabels adds required labels to "Resource"->metadata->labels.
 // It'll add those labels to all Resource (nodes with a kind property exluding a list) it can find in provided yaml.
 // Items in the yaml file could either be organised as a list or broken into multi documents.
+// Workloads also get the labels added to their pod template metadata, except those the template already carries
+// since the workload selector may rely on them.
+// It fails without changing the manifest if one of the label keys is invalid.
+// A gzip-compressed manifest is decompressed first and returned uncompressed.
 func AddAppLabels(manifestYaml []byte, appLabels map[string]string) ([]byte, error) {
+	if err := ValidateLabelKeys(appLabels); err != nil {
+		return nil, err
+	}
+
+	return transformManifest(manifestYaml, func(yamlDoc interface{}) error {
+		return addResourceLabels(yamlDoc, appLabels)
+	})
+}
+
+// AddAppLabelsToDocument adds required labels like AddAppLabels to a manifest made of a single document, such as one
+// of the documents returned by ExtractDocuments, and returns it without any "---" separator. The items of a List are
+// labeled as well, and so are the elements of a top-level sequence, which is returned as a sequence.
+// It fails if the manifest holds more than one non-empty document, and returns an empty document as it is.
+func AddAppLabelsToDocument(doc []byte, appLabels map[string]string) ([]byte, error) {
+	if err := ValidateLabelKeys(appLabels); err != nil {
+		return nil, err
+	}
+
+	doc, err := decompressManifest(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	yamlDecoder := yaml.NewDecoder(bytes.NewReader(doc))
+
+	var m interface{}
+	if err := yamlDecoder.Decode(&m); err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.Wrap(err, "failed to unmarshal yaml document")
+	}
+
+	// separators around the document are accepted, as long as they don't delimit another document
+	for {
+		var next interface{}
+		err := yamlDecoder.Decode(&next)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil || next != nil {
+			return nil, errors.New("expected a single yaml document")
+		}
+	}
+
+	if m == nil {
+		return doc, nil
+	}
+
+	// a top-level sequence holds one resource per element like in AddAppLabels, it is kept as a sequence
+	items := []interface{}{m}
+	switch v := m.(type) {
+	case map[string]interface{}:
+	case []interface{}:
+		items = v
+	default:
+		return nil, errors.New("expected a yaml mapping or sequence document")
+	}
+
+	for _, item := range items {
+		if err := addResourceLabels(item, appLabels); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := encodeDocument(m)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal yaml document")
+	}
+
+	return out, nil
+}
+
+// AddAppLabelsExcept adds required labels like AddAppLabels, except to the resources whose kind
+// matches one of excludeKinds (case-insensitively)
+func AddAppLabelsExcept(manifestYaml []byte, appLabels map[string]string, excludeKinds []string) ([]byte, error) {
+	return AddAppLabelsWithOpts(manifestYaml, appLabels, AddAppLabelsOpts{ExcludeKinds: excludeKinds})
+}
+
+// AddAppLabelsForKinds adds required labels like AddAppLabels, only to the resources whose kind
+// matches one of kinds (case-insensitively). The other resources are left as they are.
+func AddAppLabelsForKinds(manifestYaml []byte, appLabels map[string]string, kinds []string) ([]byte, error) {
+	if len(kinds) == 0 {
+		return nil, errors.New("at least one kind is required to label resources by kind")
+	}
+
+	return AddAppLabelsWithOpts(manifestYaml, appLabels, AddAppLabelsOpts{IncludeKinds: kinds})
+}
+
+// RemoveAppLabels removes the given label keys from "Resource"->metadata->labels.
+// It is the inverse of AddAppLabels and traverses the provided yaml the same way,
+// dropping the labels map entirely when it ends up empty. The pod template labels
+// used by the workload selector are kept so that the workload remains valid.
+func RemoveAppLabels(manifestYaml []byte, labelKeys []string) ([]byte, error) {
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		removeLabels(resource, labelKeys)
+		if template, ok := podTemplate(resource); ok {
+			removeTemplateLabels(template, workloadSelector(resource), labelKeys)
+		}
+		return nil
+	})
+}
+
+// StripPortainerLabels removes the labels Portainer adds to the resources it deploys, those of KubeAppLabels
+// and of GetHelmAppLabels, from every resource of a manifest and from the pod templates of workloads,
+// like RemoveAppLabels does. The other labels are kept.
+func StripPortainerLabels(manifestYaml []byte) ([]byte, error) {
+	return RemoveAppLabels(manifestYaml, []string{
+		labelPortainerAppStackID,
+		labelPortainerAppStack,
+		labelPortainerAppName,
+		labelPortainerAppOwner,
+		labelPortainerAppKind,
+	})
+}
+
+// AddAppAnnotations adds the given annotations to "Resource"->metadata->annotations.
+// It traverses the provided yaml exactly like AddAppLabels, but annotation values are
+// written as-is since they are not subject to the label value restrictions.
+func AddAppAnnotations(manifestYaml []byte, annotations map[string]string) ([]byte, error) {
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		addAnnotations(resource, annotations)
+		return nil
+	})
+}
+
+// WalkResources calls visit for every resource of a manifest, in the order they are defined. Resources are the nodes
+// with a kind property excluding lists, so the items of a list are visited instead of the list itself.
+// Changes made to the resources by visit are discarded, it stops at the first error returned by visit.
+func WalkResources(manifestYaml []byte, visit func(resource map[string]interface{}) error) error {
+	return forEachDocument(manifestYaml, func(_ int, doc map[string]interface{}) error {
+		return visitResources(doc, visit)
+	})
+}
+
+// TransformResources runs transform over every resource of a manifest like WalkResources does, and returns the
+// manifest with the changes made by transform. Resources for which transform returns false are dropped, either
+// the whole document or the item of a list, and the first error returned by transform aborts the transformation.
+// The documents are re-encoded like AddAppLabels does, in the order they are defined.
+func TransformResources(manifestYaml []byte, transform func(resource map[string]interface{}) (bool, error)) ([]byte, error) {
+	return transformResourcesWithOptions(manifestYaml, ExtractOptions{}, transform)
+}
+
+// transformResourcesWithOptions is TransformResources, encoding the documents with the indentation of opts and
+// searching for resources at most opts.MaxDepth levels deep
+func transformResourcesWithOptions(manifestYaml []byte, opts ExtractOptions, transform func(resource map[string]interface{}) (bool, error)) ([]byte, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	opts = opts.withDefaults()
+
+	manifestYaml, err := decompressManifest(manifestYaml)
+	if err != nil {
+		return nil, err
+	}
+
 	if bytes.Equal(manifestYaml, []byte("")) {
 		return manifestYaml, nil
 	}
 
-	postProcessYaml := func(yamlDoc interface{}) error {
-		addResourceLabels(yamlDoc, appLabels)
+	if opts.PreserveFormatting {
+		docs, err := transformResourcesPreserving(manifestYaml, opts, transform)
+		if err != nil {
+			return nil, err
+		}
+
+		return JoinDocuments(docs, DetectDocumentStyle(manifestYaml)), nil
+	}
+
+	docs := make([][]byte, 0)
+
+	err = decodeDocuments(bytes.NewReader(manifestYaml), opts, func(index int, m map[string]interface{}) error {
+		keep := true
+		out, err := processDocument(index, m, func(yamlDoc interface{}) error {
+			var err error
+			keep, err = filterResources(yamlDoc, opts.MaxDepth, transform)
+			return err
+		}, opts.Indent)
+		if err != nil {
+			return err
+		}
+
+		if keep {
+			docs = append(docs, out)
+		}
 		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return JoinDocuments(docs, DetectDocumentStyle(manifestYaml)), nil
+}
+
+// transformResources runs transform over every resource of the manifest, keeping all of them
+func transformResources(manifestYaml []byte, transform func(resource map[string]interface{}) error) ([]byte, error) {
+	return TransformResources(manifestYaml, func(resource map[string]interface{}) (bool, error) {
+		return true, transform(resource)
+	})
+}
+
+// transformManifest runs postProcessYaml over every document of the manifest and joins the re-encoded documents
+// back into a single multi-document yaml, keeping the leading separator and end markers of the manifest if any
+func transformManifest(manifestYaml []byte, postProcessYaml func(interface{}) error) ([]byte, error) {
+	manifestYaml, err := decompressManifest(manifestYaml)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.Equal(manifestYaml, []byte("")) {
+		return manifestYaml, nil
 	}
 
 	docs, err := ExtractDocuments(manifestYaml, postProcessYaml)
@@ -71,52 +434,271 @@ func AddAppLabels(manifestYaml []byte, appLabels map[string]string) ([]byte, err
 		return nil, err
 	}
 
+	return JoinDocuments(docs, DetectDocumentStyle(manifestYaml)), nil
+}
+
+// MergeManifests combines several manifests into a single multi-document yaml, in the order they are given.
+// Every manifest is decoded and re-encoded like ExtractDocuments does, so that leading or trailing separators
+// and empty documents in the inputs don't end up in the combined manifest.
+func MergeManifests(manifests ...[]byte) ([]byte, error) {
+	docs := make([][]byte, 0)
+
+	for i, manifest := range manifests {
+		manifestDocs, err := ExtractDocuments(manifest, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to extract documents from manifest %d", i)
+		}
+
+		docs = append(docs, manifestDocs...)
+	}
+
+	return bytes.Join(docs, []byte("---\n")), nil
+}
+
+// ToMultiDocument encodes objects into a single multi-document yaml like ExtractDocuments encodes documents,
+// in the order they are given and separated by "---\n". Nil objects are skipped.
+func ToMultiDocument(objects []map[string]interface{}) ([]byte, error) {
+	docs := make([][]byte, 0, len(objects))
+
+	for i, obj := range objects {
+		if obj == nil {
+			continue
+		}
+
+		out, err := encodeDocument(obj)
+		if err != nil {
+			return nil, errors.Wrap(newDocumentError(i, err), "failed to marshal yaml manifest")
+		}
+		docs = append(docs, out)
+	}
+
+	return bytes.Join(docs, []byte("---\n")), nil
+}
+
+// NormalizeManifest returns the canonical form of a manifest, so that semantically identical manifests are byte-for-byte equal.
+// Every document is re-encoded with sorted keys, a 2-space indentation and its aliases expanded, comments and empty
+// documents are dropped and the documents are separated by "---\n".
+func NormalizeManifest(manifestYaml []byte) ([]byte, error) {
+	docs, err := ExtractDocuments(manifestYaml, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	return bytes.Join(docs, []byte("---\n")), nil
 }
 
+// ManifestHash returns the hex encoded SHA-256 of the canonical form of a manifest returned by NormalizeManifest.
+// Manifests only differing by their formatting, key order, comments or empty documents have the same hash,
+// while any change to a value or to the order of the documents changes it.
+func ManifestHash(manifestYaml []byte) (string, error) {
+	normalized, err := NormalizeManifest(manifestYaml)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(normalized)
+	return hex.EncodeToString(sum[:]), nil
+}
+
 // ExtractDocuments extracts all the documents from a yaml file
 // Optionally post-process each document with a function, which can modify the document in place.
 // Pass in nil for postProcessYaml to skip post-processing.
+// Documents are re-encoded with sorted keys and their aliases expanded, use ExtractDocumentsPreserving to keep them as written.
+// Quoted values such as "8080" or "true" decode to strings and are quoted again when re-encoded, so they keep their type.
+// Unquoted on, off, yes, no, y and n are strings in YAML 1.2 like in kubernetes, they are quoted when re-encoded
+// so that parsers still following the YAML 1.1 boolean rules read them as strings too.
+// A gzip-compressed manifest is decompressed first, the documents are returned uncompressed.
 func ExtractDocuments(manifestYaml []byte, postProcessYaml func(interface{}) error) ([][]byte, error) {
+	return ExtractDocumentsWithOptions(manifestYaml, postProcessYaml, ExtractOptions{})
+}
+
+// ExtractDocumentsWithOptions extracts all the documents from a yaml file like ExtractDocuments,
+// using opts to override the default extraction behavior.
+func ExtractDocumentsWithOptions(manifestYaml []byte, postProcessYaml func(interface{}) error, opts ExtractOptions) ([][]byte, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	opts = opts.withDefaults()
+
+	manifestYaml, err := decompressManifest(manifestYaml)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.PreserveFormatting {
+		return extractDocumentsPreserving(manifestYaml, postProcessYaml, opts)
+	}
+
 	docs := make([][]byte, 0)
-	yamlDecoder := yaml.NewDecoder(bytes.NewReader(manifestYaml))
+
+	err = decodeDocuments(bytes.NewReader(manifestYaml), opts, func(index int, m map[string]interface{}) error {
+		// empty documents are only returned when they are kept
+		if m == nil {
+			docs = append(docs, []byte{})
+			return nil
+		}
+
+		out, err := processDocument(index, m, postProcessYaml, opts.Indent)
+		if err != nil {
+			return err
+		}
+
+		docs = append(docs, out)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// ExtractDocumentsWithSource extracts all the documents from a yaml file like ExtractDocuments, and prepends
+// a "# source: <source>" comment line to each of them so that they can be traced back to the file they come from
+// once merged with the documents of other files. The comments are dropped by the helpers that re-encode documents.
+func ExtractDocumentsWithSource(manifestYaml []byte, source string, postProcessYaml func(interface{}) error) ([][]byte, error) {
+	docs, err := ExtractDocuments(manifestYaml, postProcessYaml)
+	if err != nil {
+		return nil, err
+	}
+
+	// a line break in the source would end the comment
+	comment := []byte("# source: " + strings.Join(strings.Fields(source), " ") + "\n")
+	for i, doc := range docs {
+		docs[i] = append(append([]byte{}, comment...), doc...)
+	}
+
+	return docs, nil
+}
+
+// StreamDocuments reads the documents of a yaml stream one at a time, optionally post-processes them
+// like ExtractDocuments does and writes them to w separated by "---\n".
+// Only one document is held in memory at a time, the output is the same as joining the documents returned by ExtractDocuments.
+func StreamDocuments(r io.Reader, w io.Writer, postProcess func(interface{}) error) error {
+	first := true
+
+	return decodeDocuments(r, ExtractOptions{}, func(index int, m map[string]interface{}) error {
+		out, err := processDocument(index, m, postProcess, DefaultIndent)
+		if err != nil {
+			return err
+		}
+
+		if !first {
+			if _, err := w.Write([]byte("---\n")); err != nil {
+				return errors.Wrap(err, "failed to write yaml document separator")
+			}
+		}
+		first = false
+
+		if _, err := w.Write(out); err != nil {
+			return errors.Wrap(newDocumentError(index, err), "failed to write yaml document")
+		}
+		return nil
+	})
+}
+
+// processDocument optionally post-processes a decoded document and encodes it, indented with indent spaces
+func processDocument(index int, m map[string]interface{}, postProcessYaml func(interface{}) error, indent int) ([]byte, error) {
+	// optionally post-process yaml
+	if postProcessYaml != nil {
+		if err := postProcessYaml(m); err != nil {
+			return nil, errors.Wrap(newDocumentError(index, err), "failed to post process yaml document")
+		}
+	}
+
+	out, err := encodeDocumentIndent(m, indent)
+	if err != nil {
+		return nil, errors.Wrap(newDocumentError(index, err), "failed to marshal yaml manifest")
+	}
+
+	return out, nil
+}
+
+// forEachDocument decodes all the documents from a yaml file and calls fn for each of them, skipping empty documents.
+// fn receives the zero-based index of the document in the file.
+func forEachDocument(manifestYaml []byte, fn func(int, map[string]interface{}) error) error {
+	return decodeDocuments(bytes.NewReader(manifestYaml), ExtractOptions{}, fn)
+}
+
+// decodeDocuments is the io.Reader counterpart of forEachDocument
+func decodeDocuments(r io.Reader, opts ExtractOptions, fn func(int, map[string]interface{}) error) error {
+	reader := NewManifestReaderWithOptions(r, opts)
 
 	for {
-		m := make(map[string]interface{})
-		err := yamlDecoder.Decode(&m)
+		m, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
 
-		// if decoded document is empty
-		if m == nil {
-			continue
+		if err != nil {
+			return err
+		}
+
+		if err := fn(reader.Index(), m); err != nil {
+			return err
 		}
+	}
+}
+
+// resourceNodes returns the nodes of a yaml document that are processed as separate documents.
+// A top-level sequence, such as a JSON array or a bare yaml list of resources, holds one document per element,
+// any other document is processed as a whole.
+func resourceNodes(doc *yaml.Node) []*yaml.Node {
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	if root := doc.Content[0]; root.Kind == yaml.SequenceNode {
+		return root.Content
+	}
 
-		// if there are no more documents in the file
+	return []*yaml.Node{doc}
+}
+
+// decodeNodes decodes all the documents from a yaml stream as yaml nodes and calls fn for each of them,
+// enforcing the document count and size limits of opts
+func decodeNodes(r io.Reader, opts ExtractOptions, fn func(int, *yaml.Node) error) error {
+	reader := NewManifestReaderWithOptions(r, opts)
+
+	for {
+		index, doc, err := reader.nextNode()
 		if errors.Is(err, io.EOF) {
-			break
+			return nil
 		}
 
-		// optionally post-process yaml
-		if postProcessYaml != nil {
-			if err := postProcessYaml(m); err != nil {
-				return nil, errors.Wrap(err, "failed to post process yaml document")
-			}
+		if err != nil {
+			return err
 		}
 
-		var out bytes.Buffer
-		yamlEncoder := yaml.NewEncoder(&out)
-		yamlEncoder.SetIndent(2)
-		if err := yamlEncoder.Encode(m); err != nil {
-			return nil, errors.Wrap(err, "failed to marshal yaml manifest")
+		if err := fn(index, doc); err != nil {
+			return err
 		}
+	}
+}
 
-		docs = append(docs, out.Bytes())
+// encodeDocument encodes a single yaml document
+func encodeDocument(doc interface{}) ([]byte, error) {
+	return encodeDocumentIndent(doc, DefaultIndent)
+}
+
+// encodeDocumentIndent encodes a single yaml document, indented with indent spaces
+func encodeDocumentIndent(doc interface{}, indent int) ([]byte, error) {
+	var out bytes.Buffer
+	yamlEncoder := yaml.NewEncoder(&out)
+	yamlEncoder.SetIndent(indent)
+	if err := yamlEncoder.Encode(doc); err != nil {
+		return nil, err
 	}
 
-	return docs, nil
+	return out.Bytes(), nil
 }
 
 // GetNamespace returns the namespace of a kubernetes resource from its metadata
-// It returns an empty string if namespace is not found in the resource
+// It returns an empty string if namespace is not found in the resource.
+// For a Namespace resource it returns its name, or an empty string if it only sets metadata.generateName
+// since the actual name is only known once the api server creates it.
+// For a List it returns the namespace shared by all its items, and an error if they don't agree.
+// The cluster-scoped items other than Namespaces are ignored.
 func GetNamespace(manifestYaml []byte) (string, error) {
 	yamlDecoder := yaml.NewDecoder(bytes.NewReader(manifestYaml))
 	m := make(map[string]interface{})
@@ -125,18 +707,64 @@ func GetNamespace(manifestYaml []byte) (string, error) {
 		return "", errors.Wrap(err, "failed to unmarshal yaml manifest when obtaining namespace")
 	}
 
+	if kind, _ := m["kind"].(string); strings.EqualFold(kind, "list") {
+		return getListNamespace(m)
+	}
+
+	return getResourceNamespace(m)
+}
+
+// getListNamespace returns the namespace shared by all the items of a decoded list
+func getListNamespace(list map[string]interface{}) (string, error) {
+	namespaces := make([]string, 0)
+
+	err := visitResources(list, func(resource map[string]interface{}) error {
+		namespace, err := getResourceNamespace(resource)
+		if err != nil {
+			return err
+		}
+
+		if kind, _ := resource["kind"].(string); !namesNamespace(kind) {
+			return nil
+		}
+
+		if !containsString(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	switch len(namespaces) {
+	case 0:
+		return "", nil
+	case 1:
+		return namespaces[0], nil
+	}
+
+	return "", errors.Errorf("invalid kubernetes manifest, the items of the list are in different namespaces: '%s'", strings.Join(namespaces, "', '"))
+}
+
+// getResourceNamespace returns the namespace of a decoded kubernetes resource, or its name for a namespace resource
+func getResourceNamespace(m map[string]interface{}) (string, error) {
 	kind, ok := m["kind"].(string)
 	if !ok {
 		return "", errors.New("invalid kubernetes manifest, missing 'kind' field")
 	}
 
-	if _, ok := m["metadata"]; ok {
+	if value, ok := m["metadata"]; ok && value != nil {
+		metadata, ok := value.(map[string]interface{})
+		if !ok {
+			return "", errors.New("invalid kubernetes manifest, 'metadata' field is not an object")
+		}
+
 		var namespace interface{}
-		var ok bool
 		if strings.EqualFold(kind, "namespace") {
-			namespace, ok = m["metadata"].(map[string]interface{})["name"]
+			namespace, ok = metadata["name"]
 		} else {
-			namespace, ok = m["metadata"].(map[string]interface{})["namespace"]
+			namespace, ok = metadata["namespace"]
 		}
 
 		if ok {
@@ -149,48 +777,218 @@ func GetNamespace(manifestYaml []byte) (string, error) {
 	return "", nil
 }
 
-func addResourceLabels(yamlDoc interface{}, appLabels map[string]string) {
+func addResourceLabels(yamlDoc interface{}, appLabels map[string]string) error {
+	return visitResources(yamlDoc, func(resource map[string]interface{}) error {
+		labelResource(resource, appLabels)
+		return nil
+	})
+}
+
+// labelResource adds appLabels to a resource and to the pod template of workloads
+func labelResource(resource map[string]interface{}, appLabels map[string]string) {
+	addLabels(resource, appLabels)
+
+	// label the pods created by workloads as well, selectors are left untouched as they are immutable
+	if template, ok := podTemplate(resource); ok {
+		addMissingLabels(template, appLabels)
+	}
+}
+
+// addMissingLabels adds the appLabels that obj doesn't carry yet, existing labels are kept as they are.
+// Pod templates are labeled this way since the workload selector may rely on their existing labels.
+func addMissingLabels(obj map[string]interface{}, appLabels map[string]string) {
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	existing, _ := metadata["labels"].(map[string]interface{})
+
+	labels := make(map[string]string, len(appLabels))
+	for k, v := range appLabels {
+		if _, ok := existing[k]; !ok {
+			labels[k] = v
+		}
+	}
+
+	addLabels(obj, labels)
+}
+
+// visitResources calls visit for every resource (node with a kind property excluding a list) found in yamlDoc
+// It stops with ErrMaxDepthExceeded instead of searching for resources deeper than DefaultMaxDepth.
+func visitResources(yamlDoc interface{}, visit func(map[string]interface{}) error) error {
+	return visitResourcesDepth(yamlDoc, visit, 0, DefaultMaxDepth)
+}
+
+// visitResourcesDepth is visitResources searching for resources at most maxDepth levels deep, a negative maxDepth
+// disables the limit
+func visitResourcesDepth(yamlDoc interface{}, visit func(map[string]interface{}) error, depth, maxDepth int) error {
+	if maxDepth >= 0 && depth > maxDepth {
+		return errors.Wrapf(ErrMaxDepthExceeded, "resources searched for more than %d levels deep", maxDepth)
+	}
+
 	m, ok := yamlDoc.(map[string]interface{})
 	if !ok {
-		return
+		return nil
 	}
 
-	kind, ok := m["kind"]
-	if ok && !strings.EqualFold(kind.(string), "list") {
-		addLabels(m, appLabels)
-		return
+	// a kind that is not a string can't be a list, the resource is still visited so that it is reported as is
+	if kind, ok := m["kind"]; ok {
+		if s, _ := kind.(string); !strings.EqualFold(s, "list") {
+			return visit(m)
+		}
+	}
+
+	// visit nested nodes in key order so that resources are always reported in the same order
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case map[string]interface{}:
+			if err := visitResourcesDepth(v, visit, depth+1, maxDepth); err != nil {
+				return err
+			}
+		case []interface{}:
+			for _, item := range v {
+				if err := visitResourcesDepth(item, visit, depth+2, maxDepth); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// filterResources runs transform over every resource found in yamlDoc like visitResources does, removing the resources
+// it returns false for from the maps and lists holding them. It returns false if yamlDoc itself is such a resource.
+// Resources are searched for at most maxDepth levels deep, a negative maxDepth disables the limit.
+func filterResources(yamlDoc interface{}, maxDepth int, transform func(map[string]interface{}) (bool, error)) (bool, error) {
+	return filterResourcesDepth(yamlDoc, transform, 0, maxDepth)
+}
+
+func filterResourcesDepth(yamlDoc interface{}, transform func(map[string]interface{}) (bool, error), depth, maxDepth int) (bool, error) {
+	if maxDepth >= 0 && depth > maxDepth {
+		return false, errors.Wrapf(ErrMaxDepthExceeded, "resources searched for more than %d levels deep", maxDepth)
+	}
+
+	m, ok := yamlDoc.(map[string]interface{})
+	if !ok {
+		return true, nil
+	}
+
+	if kind, ok := m["kind"]; ok {
+		if s, _ := kind.(string); !strings.EqualFold(s, "list") {
+			return transform(m)
+		}
 	}
 
-	for _, v := range m {
-		switch v := v.(type) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch v := m[k].(type) {
 		case map[string]interface{}:
-			addResourceLabels(v, appLabels)
+			keep, err := filterResourcesDepth(v, transform, depth+1, maxDepth)
+			if err != nil {
+				return false, err
+			}
+			if !keep {
+				delete(m, k)
+			}
 		case []interface{}:
+			kept := make([]interface{}, 0, len(v))
 			for _, item := range v {
-				addResourceLabels(item, appLabels)
+				keep, err := filterResourcesDepth(item, transform, depth+2, maxDepth)
+				if err != nil {
+					return false, err
+				}
+				if keep {
+					kept = append(kept, item)
+				}
 			}
+			m[k] = kept
 		}
 	}
+
+	return true, nil
 }
 
 func addLabels(obj map[string]interface{}, appLabels map[string]string) {
+	mergeMetadataMap(obj, "labels", appLabels)
+}
+
+func addAnnotations(obj map[string]interface{}, annotations map[string]string) {
+	mergeMetadataMap(obj, "annotations", annotations)
+}
+
+// mergeMetadataMap merges values into "Resource"->metadata->field, overriding existing keys.
+// Resources whose metadata or metadata field is set to something else than a map are left untouched.
+func mergeMetadataMap(obj map[string]interface{}, field string, values map[string]string) {
 	metadata := make(map[string]interface{})
-	if m, ok := obj["metadata"]; ok {
-		metadata = m.(map[string]interface{})
+	if m, ok := obj["metadata"]; ok && m != nil {
+		if metadata, ok = m.(map[string]interface{}); !ok {
+			return
+		}
 	}
 
-	labels := make(map[string]string)
-	if l, ok := metadata["labels"]; ok {
-		for k, v := range l.(map[string]interface{}) {
-			labels[k] = fmt.Sprintf("%v", v)
+	merged := make(map[string]string)
+	if l, ok := metadata[field]; ok && l != nil {
+		existing, ok := l.(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		for k, v := range existing {
+			merged[k] = fmt.Sprintf("%v", v)
 		}
 	}
 
-	// merge app labels with existing labels
-	for k, v := range appLabels {
-		labels[k] = v
+	// merge values with existing ones
+	for k, v := range values {
+		merged[k] = v
 	}
 
-	metadata["labels"] = labels
+	metadata[field] = merged
 	obj["metadata"] = metadata
 }
+
+func removeLabels(obj map[string]interface{}, labelKeys []string) {
+	metadata, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	labels, ok := metadata["labels"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for _, k := range labelKeys {
+		delete(labels, k)
+	}
+
+	if len(labels) == 0 {
+		delete(metadata, "labels")
+	}
+}
+
+// removeTemplateLabels removes labelKeys from the labels of a pod template, except those selector matches on,
+// and drops the template metadata when nothing is left in it
+func removeTemplateLabels(template, selector map[string]interface{}, labelKeys []string) {
+	keys := make([]string, 0, len(labelKeys))
+	for _, k := range labelKeys {
+		if _, ok := selector[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	removeLabels(template, keys)
+
+	if metadata, ok := template["metadata"].(map[string]interface{}); ok && len(metadata) == 0 {
+		delete(template, "metadata")
+	}
+}
@@ -0,0 +1,161 @@
+package kubernetes
+
+import "sort"
+
+// ExtractConfigMaps returns, for every ConfigMap of a manifest, the sorted keys defined in its data and binaryData
+func ExtractConfigMaps(manifestYaml []byte) (map[string][]string, error) {
+	return extractDataKeys(manifestYaml, "configmap", "data", "binaryData")
+}
+
+// ExtractSecretKeys returns, for every Secret of a manifest, the sorted keys defined in its data and stringData.
+// Only the key names are returned, never the secret values.
+func ExtractSecretKeys(manifestYaml []byte) (map[string][]string, error) {
+	return extractDataKeys(manifestYaml, "secret", "data", "stringData")
+}
+
+// extractDataKeys returns the keys of the given fields of every resource of a kind, keyed by resource name
+func extractDataKeys(manifestYaml []byte, kind string, fields ...string) (map[string][]string, error) {
+	keys := make(map[string][]string)
+
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		if !matchesKind(resource, []string{kind}) {
+			return nil
+		}
+
+		name := metadataString(resource, "name")
+		resourceKeys := keys[name]
+		if resourceKeys == nil {
+			resourceKeys = make([]string, 0)
+		}
+
+		for _, field := range fields {
+			data, _ := resource[field].(map[string]interface{})
+			for k := range data {
+				if !containsString(resourceKeys, k) {
+					resourceKeys = append(resourceKeys, k)
+				}
+			}
+		}
+
+		sort.Strings(resourceKeys)
+		keys[name] = resourceKeys
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// configReference is a ConfigMap or Secret referenced by a pod, in the namespace of the pod
+type configReference struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// FindMissingConfigReferences returns the ConfigMaps and Secrets the pods and workloads of a manifest reference
+// but that the manifest doesn't define in the same namespace, as the sorted list of their lowercase kind and name
+// as kind/name. The references of env valueFrom, envFrom, configMap and secret volumes, projected volume sources
+// and imagePullSecrets are checked, except the ones marked as optional. This is best-effort: the objects
+// that are expected to already exist in the cluster are reported as missing too.
+func FindMissingConfigReferences(manifestYaml []byte) ([]string, error) {
+	defined := make(map[configReference]bool)
+	references := make([]configReference, 0)
+
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		namespace := effectiveNamespace(resource)
+
+		if matchesKind(resource, []string{"configmap", "secret"}) {
+			kind, _ := resource["kind"].(string)
+			defined[configReference{kind: normalizeKind(kind), namespace: namespace, name: metadataString(resource, "name")}] = true
+			return nil
+		}
+
+		spec, ok := podSpec(resource)
+		if !ok {
+			return nil
+		}
+
+		add := func(kind string, ref map[string]interface{}, nameField string) {
+			if optional, _ := ref["optional"].(bool); optional {
+				return
+			}
+
+			if name, _ := ref[nameField].(string); name != "" {
+				references = append(references, configReference{kind: kind, namespace: namespace, name: name})
+			}
+		}
+
+		forEachContainer(resource, func(container map[string]interface{}) {
+			env, _ := container["env"].([]interface{})
+			for _, e := range env {
+				entry, _ := e.(map[string]interface{})
+				if ref, ok := nestedMap(entry, "valueFrom", "configMapKeyRef"); ok {
+					add("configmap", ref, "name")
+				}
+				if ref, ok := nestedMap(entry, "valueFrom", "secretKeyRef"); ok {
+					add("secret", ref, "name")
+				}
+			}
+
+			envFrom, _ := container["envFrom"].([]interface{})
+			for _, e := range envFrom {
+				entry, _ := e.(map[string]interface{})
+				if ref, ok := nestedMap(entry, "configMapRef"); ok {
+					add("configmap", ref, "name")
+				}
+				if ref, ok := nestedMap(entry, "secretRef"); ok {
+					add("secret", ref, "name")
+				}
+			}
+		})
+
+		volumes, _ := spec["volumes"].([]interface{})
+		for _, v := range volumes {
+			volume, _ := v.(map[string]interface{})
+			if ref, ok := nestedMap(volume, "configMap"); ok {
+				add("configmap", ref, "name")
+			}
+			if ref, ok := nestedMap(volume, "secret"); ok {
+				add("secret", ref, "secretName")
+			}
+
+			projected, _ := nestedMap(volume, "projected")
+			sources, _ := projected["sources"].([]interface{})
+			for _, s := range sources {
+				source, _ := s.(map[string]interface{})
+				if ref, ok := nestedMap(source, "configMap"); ok {
+					add("configmap", ref, "name")
+				}
+				if ref, ok := nestedMap(source, "secret"); ok {
+					add("secret", ref, "name")
+				}
+			}
+		}
+
+		secrets, _ := spec["imagePullSecrets"].([]interface{})
+		for _, s := range secrets {
+			if ref, ok := s.(map[string]interface{}); ok {
+				add("secret", ref, "name")
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	missing := make([]string, 0)
+	for _, ref := range references {
+		key := ref.kind + "/" + ref.name
+		if !defined[ref] && !containsString(missing, key) {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+
+	return missing, nil
+}
@@ -0,0 +1,163 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExtractConfigMaps(t *testing.T) {
+	input := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  nginx.conf: server {}
+  mode: production
+binaryData:
+  logo.png: aGVsbG8=
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: empty
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: credentials
+    data:
+      password: c2VjcmV0
+kind: List
+`
+
+	configMaps, err := ExtractConfigMaps([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"config": {"logo.png", "mode", "nginx.conf"},
+		"empty":  {},
+	}, configMaps)
+}
+
+func Test_ExtractSecretKeys(t *testing.T) {
+	input := `apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+data:
+  password: c2VjcmV0
+stringData:
+  username: admin
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  mode: production
+`
+
+	secrets, err := ExtractSecretKeys([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"credentials": {"password", "username"}}, secrets)
+	assert.NotContains(t, secrets["credentials"], "c2VjcmV0")
+}
+
+func Test_FindMissingConfigReferences(t *testing.T) {
+	input := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db-credentials
+  namespace: other
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - env:
+            - name: THEME
+              valueFrom:
+                configMapKeyRef:
+                  key: theme
+                  name: web-config
+            - name: DB_PASSWORD
+              valueFrom:
+                secretKeyRef:
+                  key: password
+                  name: db-credentials
+            - name: OPTIONAL
+              valueFrom:
+                secretKeyRef:
+                  key: value
+                  name: optional-secret
+                  optional: true
+            - name: PLAIN
+              value: plain
+          envFrom:
+            - configMapRef:
+                name: feature-flags
+          image: web:1.0
+          name: web
+      imagePullSecrets:
+        - name: registry
+      volumes:
+        - configMap:
+            name: web-config
+          name: config
+        - name: tls
+          secret:
+            secretName: web-tls
+        - name: bundle
+          projected:
+            sources:
+              - configMap:
+                  name: ca-bundle
+              - secret:
+                  name: web-tls
+        - emptyDir: {}
+          name: cache
+---
+apiVersion: v1
+items:
+  - apiVersion: batch/v1
+    kind: CronJob
+    metadata:
+      name: backup
+    spec:
+      jobTemplate:
+        spec:
+          template:
+            spec:
+              containers:
+                - envFrom:
+                    - secretRef:
+                        name: backup-credentials
+                  image: backup:1.0
+                  name: backup
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: backup-credentials
+kind: List
+`
+
+	missing, err := FindMissingConfigReferences([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"configmap/ca-bundle",
+		"configmap/feature-flags",
+		"secret/db-credentials",
+		"secret/registry",
+		"secret/web-tls",
+	}, missing)
+}
@@ -0,0 +1,87 @@
+package kubernetes
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/encoding/json"
+	"gopkg.in/yaml.v3"
+)
+
+// ConvertToJSON converts a yaml manifest into a JSON array holding one object per document, in document order.
+// Numbers and booleans keep their types.
+func ConvertToJSON(manifestYaml []byte) ([]byte, error) {
+	docs := make([]interface{}, 0)
+
+	err := forEachDocument(manifestYaml, func(_ int, doc map[string]interface{}) error {
+		docs = append(docs, toJSONCompatible(doc))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := json.Marshal(docs)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal json manifest")
+	}
+
+	return out, nil
+}
+
+// ConvertToYAML converts a JSON array of objects, or a single JSON object, into a multi-document yaml manifest
+func ConvertToYAML(manifestJSON []byte) ([]byte, error) {
+	var value interface{}
+	// JSON is valid yaml, decoding it with the yaml decoder keeps integers as integers
+	if err := yaml.Unmarshal(manifestJSON, &value); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal json manifest")
+	}
+
+	var objects []interface{}
+	switch v := value.(type) {
+	case nil:
+		return []byte{}, nil
+	case []interface{}:
+		objects = v
+	case map[string]interface{}:
+		objects = []interface{}{v}
+	default:
+		return nil, errors.New("invalid json manifest, expected an array or an object")
+	}
+
+	docs := make([][]byte, 0, len(objects))
+	for i, object := range objects {
+		out, err := encodeDocument(object)
+		if err != nil {
+			return nil, errors.Wrap(newDocumentError(i, err), "failed to marshal yaml manifest")
+		}
+		docs = append(docs, out)
+	}
+
+	return bytes.Join(docs, []byte("---\n")), nil
+}
+
+// toJSONCompatible converts the maps with non-string keys produced by the yaml decoder into string keyed maps
+func toJSONCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = toJSONCompatible(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[fmt.Sprintf("%v", k)] = toJSONCompatible(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = toJSONCompatible(item)
+		}
+		return v
+	}
+
+	return value
+}
@@ -0,0 +1,73 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ConvertToJSON(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+    - port: 80
+      targetPort: "8080"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  paused: false
+  replicas: 3
+  selector:
+    matchLabels:
+      200: ok
+`
+
+	result, err := ConvertToJSON([]byte(input))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+  {"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web"}, "spec": {"ports": [{"port": 80, "targetPort": "8080"}]}},
+  {"apiVersion": "apps/v1", "kind": "Deployment", "metadata": {"name": "web"}, "spec": {"paused": false, "replicas": 3, "selector": {"matchLabels": {"200": "ok"}}}}
+]`, string(result))
+
+	roundTrip, err := ConvertToYAML(result)
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+    - port: 80
+      targetPort: "8080"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  paused: false
+  replicas: 3
+  selector:
+    matchLabels:
+      "200": ok
+`, string(roundTrip))
+}
+
+func Test_ConvertToYAML_SingleObject(t *testing.T) {
+	result, err := ConvertToYAML([]byte(`{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "test"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+kind: Namespace
+metadata:
+  name: test
+`, string(result))
+
+	_, err = ConvertToYAML([]byte(`"not an object"`))
+	assert.Error(t, err)
+}
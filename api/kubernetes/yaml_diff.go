@@ -0,0 +1,103 @@
+package kubernetes
+
+import (
+	"crypto/sha256"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ManifestDiff is the resource-level change set between two manifests,
+// each list being sorted by lowercase kind, effective namespace and name
+type ManifestDiff struct {
+	// Added are the resources of the new manifest missing from the old one
+	Added []ResourceRef
+	// Removed are the resources of the old manifest missing from the new one
+	Removed []ResourceRef
+	// Modified are the resources of both manifests whose content changed, referenced as in the new manifest
+	Modified []ResourceRef
+}
+
+// manifestResource is a resource of a manifest along with the hash of its canonical encoding
+type manifestResource struct {
+	ref  ResourceRef
+	hash [sha256.Size]byte
+}
+
+// DiffManifests compares the resources of two manifests, matching them by kind (case-insensitive), namespace and name.
+// Resources that don't specify a namespace are considered to be in the default namespace and items of a list are
+// compared individually. A resource is modified when its canonical encoding differs, so formatting, key order and
+// comments changes are ignored. When a manifest defines the same resource more than once, its first definition is used.
+func DiffManifests(oldYaml, newYaml []byte) (*ManifestDiff, error) {
+	oldResources, err := hashResources(oldYaml)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid old manifest")
+	}
+
+	newResources, err := hashResources(newYaml)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid new manifest")
+	}
+
+	diff := &ManifestDiff{
+		Added:    make([]ResourceRef, 0),
+		Removed:  make([]ResourceRef, 0),
+		Modified: make([]ResourceRef, 0),
+	}
+
+	for _, key := range sortedKeys(newResources) {
+		resource := newResources[key]
+		old, ok := oldResources[key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, resource.ref)
+		case old.hash != resource.hash:
+			diff.Modified = append(diff.Modified, resource.ref)
+		}
+	}
+
+	for _, key := range sortedKeys(oldResources) {
+		if _, ok := newResources[key]; !ok {
+			diff.Removed = append(diff.Removed, oldResources[key].ref)
+		}
+	}
+
+	return diff, nil
+}
+
+// hashResources returns the resources of a manifest keyed by resourceKey
+func hashResources(manifestYaml []byte) (map[string]manifestResource, error) {
+	resources := make(map[string]manifestResource)
+
+	err := forEachDocument(manifestYaml, func(index int, doc map[string]interface{}) error {
+		return visitResources(doc, func(resource map[string]interface{}) error {
+			key := resourceKey(resource)
+			if _, ok := resources[key]; ok {
+				return nil
+			}
+
+			out, err := encodeDocument(resource)
+			if err != nil {
+				return errors.Wrap(newDocumentError(index, err), "failed to marshal yaml manifest")
+			}
+
+			resources[key] = manifestResource{ref: newResourceRef(resource), hash: sha256.Sum256(out)}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+func sortedKeys(resources map[string]manifestResource) []string {
+	keys := make([]string, 0, len(resources))
+	for k := range resources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
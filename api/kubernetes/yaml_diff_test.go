@@ -0,0 +1,96 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DiffManifests(t *testing.T) {
+	oldYaml := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+    - port: 80
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: legacy
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: shop
+data:
+  key: value
+`
+
+	newYaml := `# comments, key order and formatting changes are ignored
+kind: Service
+apiVersion: v1
+spec:
+    ports: [{port: 80}]
+metadata: {name: web}
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+    spec:
+      replicas: 3
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+    data:
+      key: value
+kind: List
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+`
+
+	diff, err := DiffManifests([]byte(oldYaml), []byte(newYaml))
+	assert.NoError(t, err)
+	assert.Equal(t, &ManifestDiff{
+		Added: []ResourceRef{
+			{Kind: "ConfigMap", Name: "config", APIVersion: "v1"},
+			{Kind: "Secret", Name: "credentials", APIVersion: "v1"},
+		},
+		Removed: []ResourceRef{
+			{Kind: "ConfigMap", Name: "legacy", APIVersion: "v1"},
+			{Kind: "ConfigMap", Name: "config", Namespace: "shop", APIVersion: "v1"},
+		},
+		Modified: []ResourceRef{
+			{Kind: "Deployment", Name: "web", APIVersion: "apps/v1"},
+		},
+	}, diff)
+}
+
+func Test_DiffManifests_Identical(t *testing.T) {
+	manifest := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n"
+
+	diff, err := DiffManifests([]byte(manifest), []byte(manifest))
+	assert.NoError(t, err)
+	assert.Empty(t, diff.Added)
+	assert.Empty(t, diff.Removed)
+	assert.Empty(t, diff.Modified)
+
+	_, err = DiffManifests([]byte(manifest), []byte("kind: [\n"))
+	assert.ErrorContains(t, err, "invalid new manifest")
+}
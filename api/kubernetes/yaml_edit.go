@@ -0,0 +1,238 @@
+package kubernetes
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// rawDocument is a document of a manifest as it is written, along with the separator line preceding it
+type rawDocument struct {
+	separator []byte
+	content   []byte
+	// line is the line of the manifest the content starts at
+	line int
+}
+
+// splitRawDocuments splits a manifest on its "---" separator lines without decoding it,
+// the separator is empty for the first document unless the manifest starts with one
+func splitRawDocuments(manifestYaml []byte) []rawDocument {
+	docs := []rawDocument{{line: 1}}
+
+	for i, line := range bytes.SplitAfter(manifestYaml, []byte("\n")) {
+		if isDocumentSeparator(line) {
+			docs = append(docs, rawDocument{separator: line, line: i + 2})
+			continue
+		}
+
+		current := &docs[len(docs)-1]
+		current.content = append(current.content, line...)
+	}
+
+	if len(docs[0].content) == 0 && len(docs) > 1 {
+		docs = docs[1:]
+	}
+
+	return docs
+}
+
+// isDocumentSeparator returns true if line starts a new yaml document
+func isDocumentSeparator(line []byte) bool {
+	rest, ok := bytes.CutPrefix(line, []byte("---"))
+	return ok && (len(rest) == 0 || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r' || rest[0] == '\n')
+}
+
+func joinRawDocuments(docs []rawDocument) []byte {
+	var out bytes.Buffer
+	for _, doc := range docs {
+		out.Write(doc.separator)
+		out.Write(doc.content)
+	}
+
+	return out.Bytes()
+}
+
+// resourceLocation is the position of a resource among the decoded top-level resources of a document,
+// item is the index of the resource in the items of a list or -1 when the top-level resource is the one located
+type resourceLocation struct {
+	resources []map[string]interface{}
+	resource  int
+	item      int
+}
+
+// findRawResource returns the index of the raw document holding the first resource of the manifest with the given
+// kind (case-insensitive), name and namespace and its location in that document. An empty namespace matches any namespace.
+// Top-level resources and list items are matched.
+func findRawResource(docs []rawDocument, kind, name, namespace string) (int, resourceLocation, error) {
+	for index, doc := range docs {
+		resources := make([]map[string]interface{}, 0)
+		err := forEachDocument(doc.content, func(_ int, m map[string]interface{}) error {
+			resources = append(resources, m)
+			return nil
+		})
+		if err != nil {
+			return 0, resourceLocation{}, relocateDocumentError(err, doc, index)
+		}
+
+		for i, resource := range resources {
+			if isResourceNamed(resource, kind, name, namespace) {
+				return index, resourceLocation{resources: resources, resource: i, item: -1}, nil
+			}
+
+			if resourceKind, _ := resource["kind"].(string); !strings.EqualFold(resourceKind, "list") {
+				continue
+			}
+
+			items, _ := resource["items"].([]interface{})
+			for j, item := range items {
+				if m, ok := item.(map[string]interface{}); ok && isResourceNamed(m, kind, name, namespace) {
+					return index, resourceLocation{resources: resources, resource: i, item: j}, nil
+				}
+			}
+		}
+	}
+
+	if namespace != "" {
+		return 0, resourceLocation{}, errors.Wrapf(ErrResourceNotFound, "%s '%s' in namespace '%s'", kind, name, namespace)
+	}
+
+	return 0, resourceLocation{}, errors.Wrapf(ErrResourceNotFound, "%s '%s'", kind, name)
+}
+
+// relocateDocumentError makes the DocumentError returned when decoding a raw document on its own
+// report the position of that document in the whole manifest
+func relocateDocumentError(err error, doc rawDocument, index int) error {
+	var docErr *DocumentError
+	if errors.As(err, &docErr) {
+		docErr.Index = index
+		if docErr.Line > 0 {
+			docErr.Line += doc.line - 1
+		}
+	}
+
+	return err
+}
+
+func isResourceNamed(resource map[string]interface{}, kind, name, namespace string) bool {
+	if !matchesKind(resource, []string{kind}) || metadataString(resource, "name") != name {
+		return false
+	}
+
+	return namespace == "" || effectiveNamespace(resource) == namespace
+}
+
+// encodeResources encodes the top-level resources of a document as a multi-document yaml
+func encodeResources(index int, resources []map[string]interface{}) ([]byte, error) {
+	docs := make([][]byte, 0, len(resources))
+	for _, resource := range resources {
+		out, err := encodeDocument(resource)
+		if err != nil {
+			return nil, errors.Wrap(newDocumentError(index, err), "failed to marshal yaml manifest")
+		}
+		docs = append(docs, out)
+	}
+
+	return bytes.Join(docs, []byte("---\n")), nil
+}
+
+// ReplaceResource replaces the first resource of a manifest with the given kind (case-insensitive) and name
+// with replacement, which must hold a single resource. The other documents keep their order and formatting.
+// A replaced list item is re-encoded along with the rest of its list. It returns ErrResourceNotFound
+// instead of appending the replacement if there is no such resource.
+func ReplaceResource(manifestYaml []byte, kind, name string, replacement []byte) ([]byte, error) {
+	replacementDocs := splitRawDocuments(replacement)
+	replacementResources := make([]map[string]interface{}, 0)
+	var replacementContent []byte
+	for _, doc := range replacementDocs {
+		err := forEachDocument(doc.content, func(_ int, m map[string]interface{}) error {
+			replacementResources = append(replacementResources, m)
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid replacement resource")
+		}
+
+		if len(replacementResources) > 0 && replacementContent == nil {
+			replacementContent = doc.content
+		}
+	}
+
+	if len(replacementResources) != 1 {
+		return nil, errors.Errorf("replacement must hold a single resource, found %d", len(replacementResources))
+	}
+
+	docs := splitRawDocuments(manifestYaml)
+	index, location, err := findRawResource(docs, kind, name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if location.item == -1 && len(location.resources) == 1 {
+		if !bytes.HasSuffix(replacementContent, []byte("\n")) {
+			replacementContent = append(replacementContent, '\n')
+		}
+		docs[index].content = replacementContent
+
+		return joinRawDocuments(docs), nil
+	}
+
+	if location.item == -1 {
+		location.resources[location.resource] = replacementResources[0]
+	} else {
+		items := location.resources[location.resource]["items"].([]interface{})
+		items[location.item] = replacementResources[0]
+	}
+
+	docs[index].content, err = encodeResources(index, location.resources)
+	if err != nil {
+		return nil, err
+	}
+
+	return joinRawDocuments(docs), nil
+}
+
+// RemoveResource removes the first resource of a manifest with the given kind (case-insensitive), name and namespace.
+// An empty namespace matches any namespace, otherwise resources that don't specify one are considered to be in the
+// default namespace. The other documents keep their order and formatting. A removed list item is dropped from the
+// items of its list, which is re-encoded and kept with empty items if it was its only item.
+// It returns ErrResourceNotFound if there is no such resource.
+func RemoveResource(manifestYaml []byte, kind, name, namespace string) ([]byte, error) {
+	docs := splitRawDocuments(manifestYaml)
+	index, location, err := findRawResource(docs, kind, name, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := location.resources
+	if location.item == -1 {
+		resources = append(resources[:location.resource], resources[location.resource+1:]...)
+	} else {
+		list := resources[location.resource]
+		items := list["items"].([]interface{})
+		list["items"] = append(items[:location.item], items[location.item+1:]...)
+	}
+
+	if len(resources) > 0 {
+		docs[index].content, err = encodeResources(index, resources)
+		if err != nil {
+			return nil, err
+		}
+
+		return joinRawDocuments(docs), nil
+	}
+
+	// the document following a removed first document becomes the first one and doesn't need a separator
+	if index == 0 && len(docs[0].separator) == 0 && len(docs) > 1 && isPlainSeparator(docs[1].separator) {
+		docs[1].separator = nil
+	}
+
+	docs = append(docs[:index], docs[index+1:]...)
+
+	return joinRawDocuments(docs), nil
+}
+
+// isPlainSeparator returns true if separator is a "---" line without any comment
+func isPlainSeparator(separator []byte) bool {
+	return len(bytes.TrimSpace(separator)) == 3
+}
@@ -0,0 +1,282 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ReplaceResource(t *testing.T) {
+	input := `# frontend services
+apiVersion: v1
+kind: Service
+metadata: {name: web}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+--- # config
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  key: "value"
+`
+
+	replacement := `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3`
+
+	expected := `# frontend services
+apiVersion: v1
+kind: Service
+metadata: {name: web}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+--- # config
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  key: "value"
+`
+
+	result, err := ReplaceResource([]byte(input), "deployment", "web", []byte(replacement))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_ReplaceResource_ListItem(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata: {name: web}
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: secret
+kind: List
+`
+
+	replacement := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  key: value
+`
+
+	expected := `apiVersion: v1
+kind: Service
+metadata: {name: web}
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    data:
+      key: value
+    kind: ConfigMap
+    metadata:
+      name: config
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: secret
+kind: List
+`
+
+	result, err := ReplaceResource([]byte(input), "ConfigMap", "config", []byte(replacement))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_ReplaceResource_Errors(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+
+	replacement := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+
+	_, err := ReplaceResource([]byte(input), "Service", "api", []byte(replacement))
+	assert.ErrorIs(t, err, ErrResourceNotFound)
+
+	_, err = ReplaceResource([]byte(input), "Service", "web", []byte(replacement+"---\n"+replacement))
+	assert.ErrorContains(t, err, "replacement must hold a single resource, found 2")
+
+	_, err = ReplaceResource([]byte(input+"---\nkind: [invalid\n"), "Service", "api", []byte(replacement))
+	var docErr *DocumentError
+	assert.ErrorAs(t, err, &docErr)
+	assert.Equal(t, 1, docErr.Index)
+	assert.Equal(t, 6, docErr.Line)
+}
+
+func Test_RemoveResource(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata: {name: web}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: frontend
+--- # config
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+	tests := []struct {
+		name      string
+		kind      string
+		resource  string
+		namespace string
+		expected  string
+	}{
+		{
+			name:     "first document",
+			kind:     "service",
+			resource: "web",
+			expected: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: frontend
+--- # config
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`,
+		},
+		{
+			name:      "namespaced document",
+			kind:      "Deployment",
+			resource:  "web",
+			namespace: "frontend",
+			expected: `apiVersion: v1
+kind: Service
+metadata: {name: web}
+--- # config
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`,
+		},
+		{
+			name:     "last document",
+			kind:     "ConfigMap",
+			resource: "config",
+			expected: `apiVersion: v1
+kind: Service
+metadata: {name: web}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: frontend
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := RemoveResource([]byte(input), tt.kind, tt.resource, tt.namespace)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(result))
+		})
+	}
+
+	_, err := RemoveResource([]byte(input), "Deployment", "web", "default")
+	assert.ErrorIs(t, err, ErrResourceNotFound)
+}
+
+func Test_RemoveResource_ListItem(t *testing.T) {
+	input := `apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: secret
+kind: List
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+kind: List
+`
+
+	result, err := RemoveResource([]byte(input), "Secret", "secret", "")
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+kind: List
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+kind: List
+`, string(result))
+
+	result, err = RemoveResource([]byte(input), "Service", "web", "default")
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: secret
+kind: List
+---
+apiVersion: v1
+items: []
+kind: List
+`, string(result))
+}
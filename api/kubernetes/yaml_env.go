@@ -0,0 +1,124 @@
+package kubernetes
+
+// EnvSource is where the value of a container environment variable comes from
+type EnvSource string
+
+const (
+	// EnvSourceValue is a literal value set in the manifest, which is never reported
+	EnvSourceValue EnvSource = "value"
+	// EnvSourceSecret is a key of a Secret, or every key of a Secret for an envFrom entry
+	EnvSourceSecret EnvSource = "secret"
+	// EnvSourceConfigMap is a key of a ConfigMap, or every key of a ConfigMap for an envFrom entry
+	EnvSourceConfigMap EnvSource = "configMap"
+	// EnvSourceField is a field of the pod
+	EnvSourceField EnvSource = "field"
+	// EnvSourceResourceField is a resource limit or request of the container
+	EnvSourceResourceField EnvSource = "resourceField"
+)
+
+// EnvRef describes an environment variable of a container without its value
+type EnvRef struct {
+	// Kind and Resource identify the pod or workload the container belongs to
+	Kind     string
+	Resource string
+	// Container is the name of the container or init container
+	Container string
+	// Name is the name of the variable, or the prefix of the variables of an envFrom entry
+	Name   string
+	Source EnvSource
+	// SourceName is the name of the referenced Secret or ConfigMap, or the path of the referenced field
+	SourceName string
+	// SourceKey is the key of the referenced Secret or ConfigMap, empty for an envFrom entry
+	SourceKey string
+	// FromAll is true for envFrom entries, which import every key of the referenced Secret or ConfigMap
+	FromAll bool
+}
+
+// ExtractEnvReferences returns the environment variables of every container and init container of the pods and
+// workloads of a manifest along with where their value comes from, so that they can be reviewed without exposing
+// the values. Literal values are never returned, only the names of the referenced Secrets, ConfigMaps and fields.
+func ExtractEnvReferences(manifestYaml []byte) ([]EnvRef, error) {
+	refs := make([]EnvRef, 0)
+
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		kind, _ := resource["kind"].(string)
+
+		forEachContainer(resource, func(container map[string]interface{}) {
+			base := EnvRef{Kind: kind, Resource: resourceName(resource)}
+			base.Container, _ = container["name"].(string)
+
+			env, _ := container["env"].([]interface{})
+			for _, e := range env {
+				entry, ok := e.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				ref := base
+				ref.Name, _ = entry["name"].(string)
+				setEnvSource(&ref, entry)
+				refs = append(refs, ref)
+			}
+
+			envFrom, _ := container["envFrom"].([]interface{})
+			for _, e := range envFrom {
+				entry, ok := e.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				ref := base
+				ref.Name, _ = entry["prefix"].(string)
+				ref.FromAll = true
+				if secret, ok := entry["secretRef"].(map[string]interface{}); ok {
+					ref.Source = EnvSourceSecret
+					ref.SourceName, _ = secret["name"].(string)
+				} else if configMap, ok := entry["configMapRef"].(map[string]interface{}); ok {
+					ref.Source = EnvSourceConfigMap
+					ref.SourceName, _ = configMap["name"].(string)
+				}
+				refs = append(refs, ref)
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return refs, nil
+}
+
+// setEnvSource fills in the source of ref from an env entry, a literal value being reported without its value
+func setEnvSource(ref *EnvRef, entry map[string]interface{}) {
+	valueFrom, ok := entry["valueFrom"].(map[string]interface{})
+	if !ok {
+		ref.Source = EnvSourceValue
+		return
+	}
+
+	if selector, ok := valueFrom["secretKeyRef"].(map[string]interface{}); ok {
+		ref.Source = EnvSourceSecret
+		ref.SourceName, _ = selector["name"].(string)
+		ref.SourceKey, _ = selector["key"].(string)
+		return
+	}
+
+	if selector, ok := valueFrom["configMapKeyRef"].(map[string]interface{}); ok {
+		ref.Source = EnvSourceConfigMap
+		ref.SourceName, _ = selector["name"].(string)
+		ref.SourceKey, _ = selector["key"].(string)
+		return
+	}
+
+	if selector, ok := valueFrom["fieldRef"].(map[string]interface{}); ok {
+		ref.Source = EnvSourceField
+		ref.SourceName, _ = selector["fieldPath"].(string)
+		return
+	}
+
+	if selector, ok := valueFrom["resourceFieldRef"].(map[string]interface{}); ok {
+		ref.Source = EnvSourceResourceField
+		ref.SourceName, _ = selector["resource"].(string)
+	}
+}
@@ -0,0 +1,85 @@
+package kubernetes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExtractEnvReferences(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - env:
+            - name: LOG_LEVEL
+              value: debug
+            - name: DB_PASSWORD
+              valueFrom:
+                secretKeyRef:
+                  key: password
+                  name: db-credentials
+            - name: THEME
+              valueFrom:
+                configMapKeyRef:
+                  key: theme
+                  name: web-config
+            - name: POD_NAME
+              valueFrom:
+                fieldRef:
+                  fieldPath: metadata.name
+          envFrom:
+            - secretRef:
+                name: api-keys
+            - configMapRef:
+                name: feature-flags
+              prefix: FLAG_
+          image: web:1.0
+          name: web
+      initContainers:
+        - env:
+            - name: API_TOKEN
+              value: s3cr3t-token
+          image: migrate:1.0
+          name: migrate
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - env:
+                - name: MEMORY_LIMIT
+                  valueFrom:
+                    resourceFieldRef:
+                      resource: limits.memory
+              image: backup:1.0
+              name: backup
+`
+
+	refs, err := ExtractEnvReferences([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []EnvRef{
+		{Kind: "Deployment", Resource: "web", Container: "migrate", Name: "API_TOKEN", Source: EnvSourceValue},
+		{Kind: "Deployment", Resource: "web", Container: "web", Name: "LOG_LEVEL", Source: EnvSourceValue},
+		{Kind: "Deployment", Resource: "web", Container: "web", Name: "DB_PASSWORD", Source: EnvSourceSecret, SourceName: "db-credentials", SourceKey: "password"},
+		{Kind: "Deployment", Resource: "web", Container: "web", Name: "THEME", Source: EnvSourceConfigMap, SourceName: "web-config", SourceKey: "theme"},
+		{Kind: "Deployment", Resource: "web", Container: "web", Name: "POD_NAME", Source: EnvSourceField, SourceName: "metadata.name"},
+		{Kind: "Deployment", Resource: "web", Container: "web", Source: EnvSourceSecret, SourceName: "api-keys", FromAll: true},
+		{Kind: "Deployment", Resource: "web", Container: "web", Name: "FLAG_", Source: EnvSourceConfigMap, SourceName: "feature-flags", FromAll: true},
+		{Kind: "CronJob", Resource: "backup", Container: "backup", Name: "MEMORY_LIMIT", Source: EnvSourceResourceField, SourceName: "limits.memory"},
+	}, refs)
+
+	assert.NotContains(t, fmt.Sprintf("%+v", refs), "s3cr3t-token")
+	assert.NotContains(t, fmt.Sprintf("%+v", refs), "debug")
+}
@@ -0,0 +1,58 @@
+package kubernetes
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var yamlErrorLineRegexp = regexp.MustCompile(`line (\d+)`)
+
+// DocumentError is the error returned when a document of a manifest can't be processed
+type DocumentError struct {
+	// Index is the zero-based index of the document in the manifest
+	Index int
+	// Line is the line in the manifest reported by the yaml parser, 0 if unknown
+	Line int
+	Err  error
+}
+
+func newDocumentError(index int, err error) *DocumentError {
+	docErr := &DocumentError{Index: index, Err: err}
+
+	if match := yamlErrorLineRegexp.FindStringSubmatch(err.Error()); match != nil {
+		docErr.Line, _ = strconv.Atoi(match[1])
+	}
+
+	return docErr
+}
+
+func (e *DocumentError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("document %d (line %d): %s", e.Index, e.Line, e.Err)
+	}
+
+	return fmt.Sprintf("document %d: %s", e.Index, e.Err)
+}
+
+func (e *DocumentError) Unwrap() error {
+	return e.Err
+}
+
+// DocumentErrors is the error returned when several documents of a manifest can't be processed,
+// errors.As and errors.Is look through each of them
+type DocumentErrors []error
+
+func (e DocumentErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+func (e DocumentErrors) Unwrap() []error {
+	return e
+}
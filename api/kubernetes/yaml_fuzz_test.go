@@ -0,0 +1,32 @@
+package kubernetes
+
+import (
+	"testing"
+)
+
+func FuzzExtractDocuments(f *testing.F) {
+	seeds := []string{
+		"",
+		"---\n",
+		"apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n",
+		"kind: List\nitems:\n  - kind: Deployment\n    spec:\n      template:\n        metadata:\n          labels: {}\n",
+		"kind: Service\nmetadata: web\n---\nkind: 1\n",
+		"[{\"kind\": \"Service\", \"metadata\": {\"name\": \"web\"}}]",
+		"base: &base {kind: Service}\nother:\n  <<: *base\n",
+		"kind: [invalid\n",
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	labels := GetHelmAppLabels("best-name", "best-owner")
+
+	f.Fuzz(func(t *testing.T, manifest []byte) {
+		// errors are expected for invalid manifests, only panics fail the target
+		_, _ = ExtractDocuments(manifest, nil)
+		_, _ = ExtractDocumentsPreserving(manifest, func(doc interface{}) error {
+			return addResourceLabels(doc, labels)
+		})
+		_, _ = AddAppLabels(manifest, labels)
+	})
+}
@@ -0,0 +1,50 @@
+package kubernetes
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of the yaml tests")
+
+// Test_AddAppLabels_Golden labels every testdata/labels/<name>.input.yaml manifest and
+// compares the result with testdata/labels/<name>.golden.yaml
+func Test_AddAppLabels_Golden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "labels", "*.input.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+
+	labels := (&KubeAppLabels{StackID: 123, StackName: "best-name", Owner: "best-owner", Kind: "content"}).ToMap()
+
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".input.yaml")
+		golden := filepath.Join("testdata", "labels", name+".golden.yaml")
+
+		t.Run(name, func(t *testing.T) {
+			manifest, err := os.ReadFile(input)
+			require.NoError(t, err)
+
+			result, err := AddAppLabels(manifest, labels)
+			require.NoError(t, err)
+
+			if *updateGolden {
+				require.NoError(t, os.WriteFile(golden, result, 0644))
+			}
+
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(result))
+
+			// labeling is idempotent, the labeled manifest is left as is
+			again, err := AddAppLabels(result, labels)
+			require.NoError(t, err)
+			assert.Equal(t, string(result), string(again))
+		})
+	}
+}
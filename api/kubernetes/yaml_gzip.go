@@ -0,0 +1,44 @@
+package kubernetes
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// ErrDecompressedManifestTooLarge is returned when a gzip-compressed manifest decompresses to more than 256MiB
+var ErrDecompressedManifestTooLarge = errors.New("decompressed manifest exceeds the maximum size")
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	// maxDecompressedManifestBytes guards against the manifests that decompress to huge sizes
+	maxDecompressedManifestBytes = 256 << 20
+)
+
+// decompressManifest returns the decompressed content of a gzip-compressed manifest, detected by its magic bytes.
+// Other manifests are returned as they are, since a yaml document can't start with those bytes.
+func decompressManifest(manifestYaml []byte) ([]byte, error) {
+	if !bytes.HasPrefix(manifestYaml, gzipMagic) {
+		return manifestYaml, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(manifestYaml))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress gzip manifest")
+	}
+	defer reader.Close()
+
+	// read one byte past the limit to tell a manifest of exactly the maximum size from a larger one
+	out, err := io.ReadAll(io.LimitReader(reader, int64(maxDecompressedManifestBytes)+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress gzip manifest")
+	}
+
+	if len(out) > maxDecompressedManifestBytes {
+		return nil, ErrDecompressedManifestTooLarge
+	}
+
+	return out, nil
+}
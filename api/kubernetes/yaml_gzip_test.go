@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipManifest(t *testing.T, manifest []byte) []byte {
+	var out bytes.Buffer
+	writer := gzip.NewWriter(&out)
+	_, err := writer.Write(manifest)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	return out.Bytes()
+}
+
+func Test_GzipManifest(t *testing.T) {
+	input := []byte(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`)
+	compressed := gzipManifest(t, input)
+	labels := GetHelmAppLabels("best-name", "best-owner")
+
+	t.Run("ExtractDocuments", func(t *testing.T) {
+		expected, err := ExtractDocuments(input, nil)
+		require.NoError(t, err)
+
+		docs, err := ExtractDocuments(compressed, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, docs)
+	})
+
+	t.Run("AddAppLabels", func(t *testing.T) {
+		expected, err := AddAppLabels(input, labels)
+		require.NoError(t, err)
+
+		result, err := AddAppLabels(compressed, labels)
+		assert.NoError(t, err)
+		assert.Equal(t, string(expected), string(result))
+	})
+
+	t.Run("AddAppLabelsWithOpts", func(t *testing.T) {
+		expected, err := AddAppLabelsWithOpts(input, labels, AddAppLabelsOpts{ExcludeKinds: []string{"Service"}})
+		require.NoError(t, err)
+
+		result, err := AddAppLabelsWithOpts(compressed, labels, AddAppLabelsOpts{ExcludeKinds: []string{"Service"}})
+		assert.NoError(t, err)
+		assert.Equal(t, string(expected), string(result))
+	})
+
+	t.Run("corrupted gzip", func(t *testing.T) {
+		_, err := ExtractDocuments(compressed[:len(compressed)/2], nil)
+		assert.ErrorContains(t, err, "failed to decompress gzip manifest")
+	})
+
+	t.Run("too large once decompressed", func(t *testing.T) {
+		defer func(limit int) { maxDecompressedManifestBytes = limit }(maxDecompressedManifestBytes)
+		maxDecompressedManifestBytes = len(input) - 1
+
+		_, err := ExtractDocuments(compressed, nil)
+		assert.ErrorIs(t, err, ErrDecompressedManifestTooLarge)
+
+		maxDecompressedManifestBytes = len(input)
+		_, err = ExtractDocuments(compressed, nil)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,108 @@
+package kubernetes
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ExtractImages returns the sorted list of container images referenced by the workloads of a manifest,
+// including the images of init containers.
+func ExtractImages(manifestYaml []byte) ([]string, error) {
+	found := make(map[string]struct{})
+
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		forEachContainer(resource, func(container map[string]interface{}) {
+			if image, ok := container["image"].(string); ok && image != "" {
+				found[image] = struct{}{}
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]string, 0, len(found))
+	for image := range found {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+
+	return images, nil
+}
+
+// RewriteImages points every container image of the workloads of a manifest at the given registry.
+// Images without a registry host get the registry prepended, images pulled from another registry host
+// have their host swapped for the given registry. Repositories, tags and digests are kept as they are.
+func RewriteImages(manifestYaml []byte, registry string) ([]byte, error) {
+	registry = strings.TrimSuffix(registry, "/")
+	if registry == "" {
+		return nil, errors.New("a registry is required to rewrite images")
+	}
+
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		forEachContainer(resource, func(container map[string]interface{}) {
+			if image, ok := container["image"].(string); ok && image != "" {
+				container["image"] = rewriteImage(image, registry)
+			}
+		})
+		return nil
+	})
+}
+
+// SetImageTag sets the tag of every container image of the workloads of a manifest whose repository is imageRepo,
+// dropping their existing tag or digest. Images from other repositories are left untouched.
+func SetImageTag(manifestYaml []byte, imageRepo, tag string) ([]byte, error) {
+	if imageRepo == "" || tag == "" {
+		return nil, errors.New("an image repository and a tag are required to set image tags")
+	}
+
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		forEachContainer(resource, func(container map[string]interface{}) {
+			if image, ok := container["image"].(string); ok && imageRepository(image) == imageRepo {
+				container["image"] = imageRepo + ":" + tag
+			}
+		})
+		return nil
+	})
+}
+
+// imageRepository returns an image reference without its tag and digest
+func imageRepository(image string) string {
+	if i := strings.IndexRune(image, '@'); i != -1 {
+		image = image[:i]
+	}
+
+	// a colon after the last slash starts the tag, before it is the port of the registry host
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+
+	return image
+}
+
+func rewriteImage(image, registry string) string {
+	if strings.HasPrefix(image, registry+"/") {
+		return image
+	}
+
+	_, remainder := splitImageDomain(image)
+	return registry + "/" + remainder
+}
+
+// splitImageDomain splits an image reference into its registry host, if any, and the remainder.
+// Like docker, the first path component is a host when it contains a dot or a colon, or is localhost.
+func splitImageDomain(image string) (string, string) {
+	i := strings.IndexRune(image, '/')
+	if i == -1 {
+		return "", image
+	}
+
+	if first := image[:i]; strings.ContainsAny(first, ".:") || first == "localhost" {
+		return first, image[i+1:]
+	}
+
+	return "", image
+}
@@ -0,0 +1,178 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExtractImages(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - image: nginx:1.25
+          name: web
+        - image: busybox
+          name: sidecar
+      initContainers:
+        - image: alpine:3.19
+          name: init
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - image: registry.example.com/backup@sha256:4b8ab5c7b1a6d0b86f1e7c1a6a6f5e3e6c2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f
+              name: backup
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Pod
+    metadata:
+      name: debug
+    spec:
+      containers:
+        - image: busybox
+          name: debug
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+kind: List
+`
+
+	images, err := ExtractImages([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"alpine:3.19",
+		"busybox",
+		"nginx:1.25",
+		"registry.example.com/backup@sha256:4b8ab5c7b1a6d0b86f1e7c1a6a6f5e3e6c2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f",
+	}, images)
+}
+
+func Test_RewriteImages(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - image: nginx:1.25
+          name: web
+        - image: bitnami/redis@sha256:4b8ab5c7b1a6d0b86f1e7c1a6a6f5e3e6c2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f
+          name: cache
+        - image: quay.io/prometheus/node-exporter:v1.7.0
+          name: exporter
+        - image: localhost:5000/app
+          name: app
+        - image: mirror.internal:5000/busybox
+          name: sidecar
+      initContainers:
+        - image: alpine
+          name: init
+`
+	expected := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - image: mirror.internal:5000/nginx:1.25
+          name: web
+        - image: mirror.internal:5000/bitnami/redis@sha256:4b8ab5c7b1a6d0b86f1e7c1a6a6f5e3e6c2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f
+          name: cache
+        - image: mirror.internal:5000/prometheus/node-exporter:v1.7.0
+          name: exporter
+        - image: mirror.internal:5000/app
+          name: app
+        - image: mirror.internal:5000/busybox
+          name: sidecar
+      initContainers:
+        - image: mirror.internal:5000/alpine
+          name: init
+`
+
+	result, err := RewriteImages([]byte(input), "mirror.internal:5000/")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	_, err = RewriteImages([]byte(input), "")
+	assert.Error(t, err)
+}
+
+func Test_SetImageTag(t *testing.T) {
+	input := `apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+    spec:
+      template:
+        spec:
+          containers:
+            - image: nginx
+              name: web
+            - image: nginx:1.25
+              name: proxy
+            - image: nginx@sha256:4b8ab5c7b1a6d0b86f1e7c1a6a6f5e3e6c2b1a0f9e8d7c6b5a4f3e2d1c0b9a8f
+              name: static
+            - image: nginx-exporter:1.0
+              name: exporter
+          initContainers:
+            - image: localhost:5000/nginx:1.24
+              name: init
+kind: List
+`
+	expected := `apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+    spec:
+      template:
+        spec:
+          containers:
+            - image: nginx:1.26
+              name: web
+            - image: nginx:1.26
+              name: proxy
+            - image: nginx:1.26
+              name: static
+            - image: nginx-exporter:1.0
+              name: exporter
+          initContainers:
+            - image: localhost:5000/nginx:1.24
+              name: init
+kind: List
+`
+
+	result, err := SetImageTag([]byte(input), "nginx", "1.26")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	result, err = SetImageTag([]byte(input), "localhost:5000/nginx", "1.26")
+	assert.NoError(t, err)
+	assert.Contains(t, string(result), "image: localhost:5000/nginx:1.26")
+
+	_, err = SetImageTag([]byte(input), "nginx", "")
+	assert.Error(t, err)
+}
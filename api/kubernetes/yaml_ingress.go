@@ -0,0 +1,50 @@
+package kubernetes
+
+import "sort"
+
+// ExtractIngressHosts returns the sorted list of hostnames declared by the Ingress resources of a manifest,
+// from both their rules and their TLS blocks. Rules without a host are skipped. The networking.k8s.io/v1
+// Ingress and the older extensions/v1beta1 and networking.k8s.io/v1beta1 ones declare hosts the same way.
+func ExtractIngressHosts(manifestYaml []byte) ([]string, error) {
+	found := make(map[string]struct{})
+
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		if !matchesKind(resource, []string{"ingress"}) {
+			return nil
+		}
+
+		spec, _ := resource["spec"].(map[string]interface{})
+
+		rules, _ := spec["rules"].([]interface{})
+		for _, r := range rules {
+			rule, _ := r.(map[string]interface{})
+			if host, ok := rule["host"].(string); ok && host != "" {
+				found[host] = struct{}{}
+			}
+		}
+
+		tlsBlocks, _ := spec["tls"].([]interface{})
+		for _, t := range tlsBlocks {
+			tls, _ := t.(map[string]interface{})
+			hosts, _ := tls["hosts"].([]interface{})
+			for _, h := range hosts {
+				if host, ok := h.(string); ok && host != "" {
+					found[host] = struct{}{}
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]string, 0, len(found))
+	for host := range found {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	return hosts, nil
+}
@@ -0,0 +1,66 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExtractIngressHosts(t *testing.T) {
+	input := `apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  rules:
+    - host: www.example.com
+      http:
+        paths:
+          - backend:
+              service:
+                name: web
+                port:
+                  number: 80
+            path: /
+            pathType: Prefix
+    - host: api.example.com
+    - http:
+        paths:
+          - path: /fallback
+  tls:
+    - hosts:
+        - www.example.com
+        - secure.example.com
+      secretName: web-tls
+---
+apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: legacy
+spec:
+  rules:
+    - host: legacy.example.com
+      http:
+        paths:
+          - backend:
+              serviceName: legacy
+              servicePort: 80
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  externalName: ignored.example.com
+`
+
+	hosts, err := ExtractIngressHosts([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"api.example.com", "legacy.example.com", "secure.example.com", "www.example.com"}, hosts)
+}
+
+func Test_ExtractIngressHosts_NoIngress(t *testing.T) {
+	hosts, err := ExtractIngressHosts([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n"))
+	assert.NoError(t, err)
+	assert.Empty(t, hosts)
+}
@@ -0,0 +1,412 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/portainer/portainer/api/kubernetes/validation"
+
+	"github.com/pkg/errors"
+)
+
+// LabelConflictPolicy decides what happens when a resource already carries one of the app labels with a different value
+type LabelConflictPolicy int
+
+const (
+	// LabelConflictOverwrite replaces the existing value with the app label value, this is the AddAppLabels behavior
+	LabelConflictOverwrite LabelConflictPolicy = iota
+	// LabelConflictPreserve keeps the existing value
+	LabelConflictPreserve
+	// LabelConflictError fails with a descriptive error
+	LabelConflictError
+)
+
+// AddAppLabelsOpts holds the options of AddAppLabelsWithOpts
+type AddAppLabelsOpts struct {
+	// OnConflict is applied when an app label key already exists with a different value
+	OnConflict LabelConflictPolicy
+	// IncludeKinds lists the kinds (case-insensitive) of the only resources that are labeled, every kind when empty
+	IncludeKinds []string
+	// ExcludeKinds lists the kinds (case-insensitive) of the resources that are not labeled
+	ExcludeKinds []string
+	// SkipClusterScoped skips the cluster-scoped resources, those of the built-in cluster-scoped kinds
+	// and those whose kind (case-insensitive) is one of ClusterScopedKinds
+	SkipClusterScoped bool
+	// ClusterScopedKinds lists the kinds of the cluster-scoped custom resources, such as ClusterIssuer,
+	// skipped along with the built-in ones when SkipClusterScoped is set
+	ClusterScopedKinds []string
+	// Indent is the number of spaces, between 2 and 9, the labeled documents are indented with.
+	// Defaults to DefaultIndent when zero.
+	Indent int
+	// PreserveFormatting keeps the comments, key ordering and formatting of the manifest instead of re-encoding
+	// its documents from scratch, see ExtractOptions.PreserveFormatting
+	PreserveFormatting bool
+	// MaxDepth is the maximum nesting depth of the documents, and of the resources searched for in them.
+	// Defaults to DefaultMaxDepth when zero, a negative value disables the limit, see ExtractOptions.MaxDepth
+	MaxDepth int
+}
+
+// AddAppLabelsWithOpts adds required labels like AddAppLabels, using opts to decide
+// which resources are labeled and how existing values are handled
+func AddAppLabelsWithOpts(manifestYaml []byte, appLabels map[string]string, opts AddAppLabelsOpts) ([]byte, error) {
+	if err := ValidateLabelKeys(appLabels); err != nil {
+		return nil, err
+	}
+
+	return transformResourcesWithOptions(manifestYaml, ExtractOptions{Indent: opts.Indent, PreserveFormatting: opts.PreserveFormatting, MaxDepth: opts.MaxDepth}, func(resource map[string]interface{}) (bool, error) {
+		if len(opts.IncludeKinds) > 0 && !matchesKind(resource, opts.IncludeKinds) {
+			return true, nil
+		}
+
+		if matchesKind(resource, opts.ExcludeKinds) {
+			return true, nil
+		}
+
+		if kind, _ := resource["kind"].(string); opts.SkipClusterScoped && (isClusterScoped(kind) || matchesKind(resource, opts.ClusterScopedKinds)) {
+			return true, nil
+		}
+
+		return true, labelResourceWithPolicy(resource, appLabels, opts.OnConflict)
+	})
+}
+
+// ValidateLabelKeys returns an error describing all the keys of labels that are not valid kubernetes label keys.
+// A key is made of a name of at most 63 alphanumeric characters, '-', '_' or '.' starting and ending with an
+// alphanumeric character, optionally preceded by a DNS subdomain prefix of at most 253 characters and a slash.
+func ValidateLabelKeys(labels map[string]string) error {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	problems := make([]string, 0)
+	for _, k := range keys {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			problems = append(problems, fmt.Sprintf("'%s': %s", k, strings.Join(errs, ", ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("invalid label keys: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// AddAppLabelsNamespacedOnly adds required labels like AddAppLabels, except to the cluster-scoped resources
+// such as ClusterRole, PersistentVolume or StorageClass. Use AddAppLabelsWithOpts with ClusterScopedKinds to skip
+// cluster-scoped custom resources as well.
+func AddAppLabelsNamespacedOnly(manifestYaml []byte, appLabels map[string]string) ([]byte, error) {
+	return AddAppLabelsWithOpts(manifestYaml, appLabels, AddAppLabelsOpts{SkipClusterScoped: true})
+}
+
+// labelResourceWithPolicy adds appLabels to a resource and to the pod template of workloads, resolving conflicts with policy
+func labelResourceWithPolicy(resource map[string]interface{}, appLabels map[string]string, policy LabelConflictPolicy) error {
+	labels, err := resolveLabelConflicts(resource, resource, appLabels, policy)
+	if err != nil {
+		return err
+	}
+	addLabels(resource, labels)
+
+	template, ok := podTemplate(resource)
+	if !ok {
+		return nil
+	}
+
+	// existing pod template labels are never replaced, the workload selector may rely on them
+	if policy == LabelConflictOverwrite {
+		policy = LabelConflictPreserve
+	}
+
+	labels, err = resolveLabelConflicts(resource, template, appLabels, policy)
+	if err != nil {
+		return err
+	}
+	addLabels(template, labels)
+
+	return nil
+}
+
+// resolveLabelConflicts returns the labels to merge into obj, which is either resource or its pod template
+func resolveLabelConflicts(resource, obj map[string]interface{}, appLabels map[string]string, policy LabelConflictPolicy) (map[string]string, error) {
+	if policy == LabelConflictOverwrite {
+		return appLabels, nil
+	}
+
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	existing, _ := metadata["labels"].(map[string]interface{})
+
+	labels := make(map[string]string, len(appLabels))
+	for k, v := range appLabels {
+		current, ok := existing[k]
+		if !ok || fmt.Sprintf("%v", current) == v {
+			labels[k] = v
+			continue
+		}
+
+		if policy == LabelConflictError {
+			return nil, errors.Errorf("label '%s' of %s is already set to '%v', refusing to replace it with '%s'", k, resourceKey(resource), current, v)
+		}
+	}
+
+	return labels, nil
+}
+
+// AddHelmAppLabels adds the GetHelmAppLabels labels to every resource of a rendered helm chart like AddAppLabels,
+// hooks and subchart resources included, except to the CustomResourceDefinitions which helm installs from the
+// crds directory of charts and never upgrades or deletes along with the release
+func AddHelmAppLabels(manifestYaml []byte, name, owner string) ([]byte, error) {
+	appLabels := GetHelmAppLabels(name, owner)
+	if err := ValidateLabelKeys(appLabels); err != nil {
+		return nil, err
+	}
+
+	return transformManifest(manifestYaml, func(yamlDoc interface{}) error {
+		return visitResources(yamlDoc, func(resource map[string]interface{}) error {
+			if !matchesKind(resource, []string{"customresourcedefinition"}) {
+				labelResource(resource, appLabels)
+			}
+			return nil
+		})
+	})
+}
+
+// GetHelmReleaseInfo returns the name and owner of the Portainer helm release a rendered chart belongs to,
+// read from the labels of the first resource carrying both of the GetHelmAppLabels labels.
+// It returns an empty name and no error when the manifest has no such resource.
+func GetHelmReleaseInfo(manifestYaml []byte) (name string, owner string, err error) {
+	found := false
+
+	err = WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		if found {
+			return nil
+		}
+
+		labels := resourceLabels(resource)
+		n, hasName := labels[labelPortainerAppName]
+		o, hasOwner := labels[labelPortainerAppOwner]
+		if hasName && hasOwner {
+			name, owner, found = n, o, true
+		}
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return name, owner, nil
+}
+
+// IsPortainerManaged returns true if a resource of a manifest carries the Portainer stack id label,
+// which means the manifest was previously deployed as a Portainer stack
+func IsPortainerManaged(manifestYaml []byte) (bool, error) {
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		if _, ok := resourceLabels(resource)[labelPortainerAppStackID]; ok {
+			return errStopVisit
+		}
+		return nil
+	})
+	if errors.Is(err, errStopVisit) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// GetResourceLabels returns a copy of the labels of the first resource of a manifest with the given kind
+// (case-insensitive) and name, with their values converted to strings. It returns an empty map if the resource
+// has no labels and ErrResourceNotFound if there is no such resource.
+func GetResourceLabels(manifestYaml []byte, kind, name string) (map[string]string, error) {
+	return getResourceMetadataMap(manifestYaml, kind, name, "labels")
+}
+
+// GetResourceAnnotations returns a copy of the annotations of the first resource of a manifest with the given kind
+// (case-insensitive) and name, with their values converted to strings. It returns an empty map if the resource
+// has no annotations and ErrResourceNotFound if there is no such resource.
+func GetResourceAnnotations(manifestYaml []byte, kind, name string) (map[string]string, error) {
+	return getResourceMetadataMap(manifestYaml, kind, name, "annotations")
+}
+
+// getResourceMetadataMap returns "Resource"->metadata->field of the first resource with the given kind and name
+func getResourceMetadataMap(manifestYaml []byte, kind, name, field string) (map[string]string, error) {
+	var values map[string]string
+
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		if !isResourceNamed(resource, kind, name, "") {
+			return nil
+		}
+
+		values = resourceMetadataMap(resource, field)
+		return errStopVisit
+	})
+	if errors.Is(err, errStopVisit) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, errors.Wrapf(ErrResourceNotFound, "%s '%s'", kind, name)
+}
+
+// resourceLabels returns "Resource"->metadata->labels with their values converted to strings
+func resourceLabels(resource map[string]interface{}) map[string]string {
+	return resourceMetadataMap(resource, "labels")
+}
+
+// resourceMetadataMap returns the "Resource"->metadata->field map with its values converted to strings
+func resourceMetadataMap(resource map[string]interface{}, field string) map[string]string {
+	metadata, _ := resource["metadata"].(map[string]interface{})
+	existing, _ := metadata[field].(map[string]interface{})
+
+	values := make(map[string]string, len(existing))
+	for k, v := range existing {
+		values[k] = fmt.Sprintf("%v", v)
+	}
+
+	return values
+}
+
+// DiffLabels returns, for every resource of a manifest that drifted from the expected labels, the sorted keys
+// of the expected labels that are missing or have a different value. Resources are keyed by their lowercase
+// kind and name as kind/name, and resources carrying all the expected labels are not reported.
+func DiffLabels(manifestYaml []byte, expected map[string]string) (map[string][]string, error) {
+	diff := make(map[string][]string)
+
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		labels := resourceLabels(resource)
+
+		kind, _ := resource["kind"].(string)
+		key := normalizeKind(kind) + "/" + resourceName(resource)
+
+		// resources with the same kind and name in different namespaces share a key
+		drifted := diff[key]
+		for k, v := range expected {
+			if current, ok := labels[k]; (!ok || current != v) && !containsString(drifted, k) {
+				drifted = append(drifted, k)
+			}
+		}
+
+		if len(drifted) > 0 {
+			sort.Strings(drifted)
+			diff[key] = drifted
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return diff, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FindUnlabeledResources returns every resource of a manifest missing the owner label Portainer adds to the
+// resources it deploys, in the order they are defined, so that they can be labeled again. Items of a list are
+// checked individually. Only the labels of the resources themselves are checked: the pod templates of workloads
+// are labeled along with their workload and relabeling the workload fixes them as well.
+func FindUnlabeledResources(manifestYaml []byte) ([]ResourceRef, error) {
+	refs := make([]ResourceRef, 0)
+
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		if _, ok := resourceLabels(resource)[labelPortainerAppOwner]; !ok {
+			refs = append(refs, newResourceRef(resource))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return refs, nil
+}
+
+// RenameLabelKeys renames the "Resource"->metadata->labels keys of every resource of a manifest found in renames
+// to the key they are mapped to, keeping their value. When a resource already has a label with the new key,
+// its value is replaced if overwrite is true, otherwise the resource keeps both labels as they are.
+// Every rename is computed from the original labels, so keys can be swapped or renamed in a chain.
+// The labels of pod templates are left untouched since they have to keep matching the immutable workload selectors.
+func RenameLabelKeys(manifestYaml []byte, renames map[string]string, overwrite bool) ([]byte, error) {
+	newKeys := make(map[string]string, len(renames))
+	for _, k := range renames {
+		newKeys[k] = ""
+	}
+	if err := ValidateLabelKeys(newKeys); err != nil {
+		return nil, err
+	}
+
+	// rename in a fixed order so that the result doesn't depend on the iteration order of renames
+	oldKeys := make([]string, 0, len(renames))
+	for k := range renames {
+		oldKeys = append(oldKeys, k)
+	}
+	sort.Strings(oldKeys)
+
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		metadata, _ := resource["metadata"].(map[string]interface{})
+		labels, ok := metadata["labels"].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		metadata["labels"] = renameKeys(labels, renames, oldKeys, overwrite)
+		return nil
+	})
+}
+
+// renameKeys returns labels with the keys of renames renamed, oldKeys being the sorted keys of renames.
+// A key only counts as taken when it's kept, a key renamed itself frees its slot.
+func renameKeys(labels map[string]interface{}, renames map[string]string, oldKeys []string, overwrite bool) map[string]interface{} {
+	moved := make(map[string]bool)
+	for _, oldKey := range oldKeys {
+		if _, ok := labels[oldKey]; ok && renames[oldKey] != oldKey {
+			moved[oldKey] = true
+		}
+	}
+
+	// without overwrite, drop the renames to a key that is kept or already claimed by another rename
+	// until none is left, as a dropped rename keeps its key in turn
+	for changed := !overwrite; changed; {
+		changed = false
+		claimed := make(map[string]bool)
+		for _, oldKey := range oldKeys {
+			if !moved[oldKey] {
+				continue
+			}
+
+			newKey := renames[oldKey]
+			if _, exists := labels[newKey]; claimed[newKey] || (exists && !moved[newKey]) {
+				moved[oldKey] = false
+				changed = true
+				continue
+			}
+			claimed[newKey] = true
+		}
+	}
+
+	renamed := make(map[string]interface{}, len(labels))
+	for k, v := range labels {
+		if !moved[k] {
+			renamed[k] = v
+		}
+	}
+
+	for _, oldKey := range oldKeys {
+		if moved[oldKey] {
+			renamed[renames[oldKey]] = labels[oldKey]
+		}
+	}
+
+	return renamed
+}
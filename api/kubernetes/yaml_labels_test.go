@@ -0,0 +1,764 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_AddAppLabelsWithOpts(t *testing.T) {
+	labels := GetHelmAppLabels("best-name", "best-owner")
+
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    io.portainer.kubernetes.application.owner: someone-else
+  name: web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+`
+
+	tests := []struct {
+		name     string
+		policy   LabelConflictPolicy
+		expected string
+		wantErr  string
+	}{
+		{
+			name:   "overwrite replaces the existing value",
+			policy: LabelConflictOverwrite,
+			expected: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+        io.portainer.kubernetes.application.name: best-name
+        io.portainer.kubernetes.application.owner: best-owner
+`,
+		},
+		{
+			name:   "preserve keeps the existing value",
+			policy: LabelConflictPreserve,
+			expected: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: someone-else
+  name: web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+        io.portainer.kubernetes.application.name: best-name
+        io.portainer.kubernetes.application.owner: best-owner
+`,
+		},
+		{
+			name:    "error reports the conflict",
+			policy:  LabelConflictError,
+			wantErr: "label 'io.portainer.kubernetes.application.owner' of deployment/default/web is already set to 'someone-else'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := AddAppLabelsWithOpts([]byte(input), labels, AddAppLabelsOpts{OnConflict: tt.policy})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(result))
+		})
+	}
+}
+
+func Test_AddAppLabelsWithOpts_SameValueIsNotAConflict(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+  name: web
+`
+
+	result, err := AddAppLabelsWithOpts([]byte(input), GetHelmAppLabels("best-name", "best-owner"), AddAppLabelsOpts{OnConflict: LabelConflictError})
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: web
+`, string(result))
+}
+
+func Test_GetHelmReleaseInfo(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expectedName  string
+		expectedOwner string
+	}{
+		{
+			name: "labels of the first labeled resource",
+			input: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: partial
+  name: config
+---
+apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: other-name
+    io.portainer.kubernetes.application.owner: other-owner
+  name: api
+`,
+			expectedName:  "best-name",
+			expectedOwner: "best-owner",
+		},
+		{
+			name: "foreign chart",
+			input: `apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    app.kubernetes.io/name: web
+  name: web
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, owner, err := GetHelmReleaseInfo([]byte(tt.input))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedName, name)
+			assert.Equal(t, tt.expectedOwner, owner)
+		})
+	}
+}
+
+func Test_DiffLabels(t *testing.T) {
+	expected := GetHelmAppLabels("best-name", "best-owner")
+
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: someone-else
+  name: web
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+kind: List
+`
+
+	diff, err := DiffLabels([]byte(input), expected)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"deployment/web": {"io.portainer.kubernetes.application.owner"},
+		"configmap/config": {
+			"io.portainer.kubernetes.application.name",
+			"io.portainer.kubernetes.application.owner",
+		},
+	}, diff)
+}
+
+func Test_AddAppLabelsNamespacedOnly(t *testing.T) {
+	input := `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+apiVersion: cert-manager.io/v1
+kind: ClusterIssuer
+metadata:
+  name: letsencrypt
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+	expected := `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+apiVersion: cert-manager.io/v1
+kind: ClusterIssuer
+metadata:
+  name: letsencrypt
+---
+apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: web
+`
+
+	labels := GetHelmAppLabels("best-name", "best-owner")
+
+	result, err := AddAppLabelsWithOpts([]byte(input), labels, AddAppLabelsOpts{
+		SkipClusterScoped:  true,
+		ClusterScopedKinds: []string{"clusterIssuer"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	// custom resources are not known to be cluster-scoped unless listed
+	result, err = AddAppLabelsNamespacedOnly([]byte(input), labels)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Replace(expected, `kind: ClusterIssuer
+metadata:
+  name: letsencrypt`, `kind: ClusterIssuer
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: letsencrypt`, 1), string(result))
+
+	assert.Contains(t, BuiltinClusterScopedKinds(), "clusterrole")
+	assert.NotContains(t, BuiltinClusterScopedKinds(), "clusterissuer")
+}
+
+func Test_AddAppLabelsWithOpts_PreserveFormatting(t *testing.T) {
+	input := `# web frontend
+kind: Deployment
+apiVersion: apps/v1
+metadata:
+  name: web # keep this name
+  labels:
+    app: web
+spec:
+  replicas: 2
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.25 # pinned
+---
+# not labeled
+kind: ConfigMap
+apiVersion: v1
+metadata:
+  name: config
+`
+	expected := `# web frontend
+kind: Deployment
+apiVersion: apps/v1
+metadata:
+  name: web # keep this name
+  labels:
+    app: web
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+spec:
+  replicas: 2
+  template:
+    metadata:
+      labels:
+        app: web
+        io.portainer.kubernetes.application.name: best-name
+        io.portainer.kubernetes.application.owner: best-owner
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.25 # pinned
+---
+# not labeled
+kind: ConfigMap
+apiVersion: v1
+metadata:
+  name: config
+`
+
+	result, err := AddAppLabelsWithOpts([]byte(input), GetHelmAppLabels("best-name", "best-owner"), AddAppLabelsOpts{
+		ExcludeKinds:       []string{"configmap"},
+		PreserveFormatting: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_AddAppLabelsWithOpts_MaxDepth(t *testing.T) {
+	labels := map[string]string{"app": "web"}
+
+	// 60 nested lists put the ConfigMap 120 levels deep, past DefaultMaxDepth
+	input := strings.Repeat("{kind: List, items: [", 60) + "{kind: ConfigMap, metadata: {name: config}}" + strings.Repeat("]}", 60) + "\n"
+
+	_, err := AddAppLabelsWithOpts([]byte(input), labels, AddAppLabelsOpts{})
+	assert.ErrorIs(t, err, ErrMaxDepthExceeded)
+
+	_, err = AddAppLabelsWithOpts([]byte(input), labels, AddAppLabelsOpts{MaxDepth: 110})
+	assert.ErrorIs(t, err, ErrMaxDepthExceeded)
+
+	for _, maxDepth := range []int{200, -1} {
+		for _, preserve := range []bool{false, true} {
+			result, err := AddAppLabelsWithOpts([]byte(input), labels, AddAppLabelsOpts{MaxDepth: maxDepth, PreserveFormatting: preserve})
+			assert.NoError(t, err)
+			assert.Equal(t, 1, strings.Count(string(result), "app: web"))
+		}
+	}
+}
+
+func Test_ValidateLabelKeys(t *testing.T) {
+	assert.NoError(t, ValidateLabelKeys(GetHelmAppLabels("best-name", "best-owner")))
+	assert.NoError(t, ValidateLabelKeys(map[string]string{"app.kubernetes.io/name": "web", "App_Name": "web"}))
+
+	err := ValidateLabelKeys(map[string]string{
+		"valid":                                  "value",
+		"Example.com/name":                       "value",
+		"example.com/" + strings.Repeat("a", 64): "value",
+		"a/b/c":                                  "value",
+	})
+	assert.ErrorContains(t, err, "invalid label keys: ")
+	assert.ErrorContains(t, err, "'Example.com/name': prefix part must match the regex")
+	assert.ErrorContains(t, err, "name part must be no more than 63 characters")
+	assert.ErrorContains(t, err, "'a/b/c': a qualified name must match the regex")
+	assert.NotContains(t, err.Error(), "'valid'")
+
+	_, err = AddAppLabels([]byte("kind: Service\n"), map[string]string{"bad key": "value"})
+	assert.ErrorContains(t, err, "'bad key'")
+}
+
+func Test_IsPortainerManaged(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{
+			name: "stack resource in a list",
+			input: `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      labels:
+        io.portainer.kubernetes.application.stackid: "12"
+      name: config
+kind: List
+`,
+			want: true,
+		},
+		{
+			name: "helm labels only",
+			input: `apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: web
+`,
+		},
+		{
+			name:  "empty manifest",
+			input: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			managed, err := IsPortainerManaged([]byte(tt.input))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, managed)
+		})
+	}
+}
+
+func Test_GetResourceLabels(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      labels:
+        app: web
+        io.portainer.kubernetes.application.stackid: 12
+        enabled: true
+      name: web
+kind: List
+`
+
+	labels, err := GetResourceLabels([]byte(input), "deployment", "web")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"app": "web",
+		"io.portainer.kubernetes.application.stackid": "12",
+		"enabled": "true",
+	}, labels)
+
+	labels, err = GetResourceLabels([]byte(input), "Service", "web")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{}, labels)
+
+	_, err = GetResourceLabels([]byte(input), "Service", "api")
+	assert.ErrorIs(t, err, ErrResourceNotFound)
+}
+
+func Test_GetResourceAnnotations(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    app: web
+  name: web
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      annotations:
+        deployment.kubernetes.io/revision: 3
+        description: the web frontend
+        prometheus.io/scrape: true
+      name: web
+kind: List
+`
+
+	annotations, err := GetResourceAnnotations([]byte(input), "deployment", "web")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"deployment.kubernetes.io/revision": "3",
+		"description":                       "the web frontend",
+		"prometheus.io/scrape":              "true",
+	}, annotations)
+
+	annotations, err = GetResourceAnnotations([]byte(input), "Service", "web")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{}, annotations)
+
+	_, err = GetResourceAnnotations([]byte(input), "Service", "api")
+	assert.ErrorIs(t, err, ErrResourceNotFound)
+}
+
+func Test_AddHelmAppLabels(t *testing.T) {
+	input := `---
+# Source: app/crds/widgets.yaml
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+---
+# Source: app/charts/cache/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: release-cache
+spec:
+  ports:
+    - port: 6379
+---
+# Source: app/templates/tests/test-connection.yaml
+apiVersion: batch/v1
+kind: Job
+metadata:
+  annotations:
+    helm.sh/hook: post-install
+    helm.sh/hook-delete-policy: hook-succeeded
+  name: release-migrate
+spec:
+  template:
+    spec:
+      containers:
+        - image: migrate:1.0
+          name: migrate
+      restartPolicy: Never
+`
+
+	expected := `---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+---
+apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: release-cache
+spec:
+  ports:
+    - port: 6379
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  annotations:
+    helm.sh/hook: post-install
+    helm.sh/hook-delete-policy: hook-succeeded
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: release-migrate
+spec:
+  template:
+    metadata:
+      labels:
+        io.portainer.kubernetes.application.name: best-name
+        io.portainer.kubernetes.application.owner: best-owner
+    spec:
+      containers:
+        - image: migrate:1.0
+          name: migrate
+      restartPolicy: Never
+`
+
+	result, err := AddHelmAppLabels([]byte(input), "best-name", "best-owner")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_FindUnlabeledResources(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    io.portainer.kubernetes.application.owner: best-owner
+  name: web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+  name: web
+  namespace: shop
+---
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      labels:
+        io.portainer.kubernetes.application.owner: best-owner
+      name: credentials
+kind: List
+`
+
+	refs, err := FindUnlabeledResources([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []ResourceRef{
+		{Kind: "Service", Name: "web", Namespace: "shop", APIVersion: "v1"},
+		{Kind: "ConfigMap", Name: "config", APIVersion: "v1"},
+	}, refs)
+}
+
+func Test_RenameLabelKeys(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: web
+    tier: frontend
+  name: web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      labels:
+        app: web
+        app.kubernetes.io/name: legacy
+      name: web
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+kind: List
+`
+
+	renames := map[string]string{"app": "app.kubernetes.io/name", "tier": "app.kubernetes.io/component"}
+
+	tests := []struct {
+		name         string
+		overwrite    bool
+		serviceLabel string
+	}{
+		{
+			name:         "existing keys are skipped",
+			serviceLabel: "        app: web\n        app.kubernetes.io/name: legacy\n",
+		},
+		{
+			name:         "existing keys are overwritten",
+			overwrite:    true,
+			serviceLabel: "        app.kubernetes.io/name: web\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app.kubernetes.io/component: frontend
+    app.kubernetes.io/name: web
+  name: web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      labels:
+` + tt.serviceLabel + `      name: web
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+kind: List
+`
+
+			result, err := RenameLabelKeys([]byte(input), renames, tt.overwrite)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, string(result))
+		})
+	}
+
+	_, err := RenameLabelKeys([]byte(input), map[string]string{"app": "not a key"}, false)
+	assert.ErrorContains(t, err, "invalid label keys")
+}
+
+func Test_RenameLabelKeys_SwapAndChain(t *testing.T) {
+	tests := []struct {
+		name      string
+		labels    string
+		renames   map[string]string
+		overwrite bool
+		expected  map[string]string
+	}{
+		{
+			name:      "swap with overwrite",
+			labels:    "a: one\n    b: two",
+			renames:   map[string]string{"a": "b", "b": "a"},
+			overwrite: true,
+			expected:  map[string]string{"a": "two", "b": "one"},
+		},
+		{
+			name:     "swap without overwrite",
+			labels:   "a: one\n    b: two",
+			renames:  map[string]string{"a": "b", "b": "a"},
+			expected: map[string]string{"a": "two", "b": "one"},
+		},
+		{
+			name:      "chain with overwrite",
+			labels:    "a: one\n    b: two",
+			renames:   map[string]string{"a": "b", "b": "c"},
+			overwrite: true,
+			expected:  map[string]string{"b": "one", "c": "two"},
+		},
+		{
+			name:      "chain overwriting the last key",
+			labels:    "a: one\n    b: two\n    c: three",
+			renames:   map[string]string{"a": "b", "b": "c"},
+			overwrite: true,
+			expected:  map[string]string{"b": "one", "c": "two"},
+		},
+		{
+			name:     "chain blocked by the last key",
+			labels:   "a: one\n    b: two\n    c: three",
+			renames:  map[string]string{"a": "b", "b": "c"},
+			expected: map[string]string{"a": "one", "b": "two", "c": "three"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n  labels:\n    " + tt.labels + "\n"
+
+			result, err := RenameLabelKeys([]byte(input), tt.renames, tt.overwrite)
+			assert.NoError(t, err)
+
+			labels, err := GetResourceLabels(result, "ConfigMap", "config")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, labels)
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package kubernetes
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/portainer/portainer/api/kubernetes/validation"
+
+	"github.com/pkg/errors"
+)
+
+// clusterScopedKinds holds the lowercase kinds of the built-in resources that are cluster-scoped, it is never modified
+var clusterScopedKinds = map[string]bool{
+	"namespace":                      true,
+	"node":                           true,
+	"persistentvolume":               true,
+	"clusterrole":                    true,
+	"clusterrolebinding":             true,
+	"storageclass":                   true,
+	"csidriver":                      true,
+	"csinode":                        true,
+	"ingressclass":                   true,
+	"priorityclass":                  true,
+	"runtimeclass":                   true,
+	"customresourcedefinition":       true,
+	"apiservice":                     true,
+	"mutatingwebhookconfiguration":   true,
+	"validatingwebhookconfiguration": true,
+	"podsecuritypolicy":              true,
+	"volumeattachment":               true,
+	"certificatesigningrequest":      true,
+}
+
+// BuiltinClusterScopedKinds returns the sorted lowercase kinds of the built-in resources known to be cluster-scoped.
+// Cluster-scoped custom resources are not included, see AddAppLabelsOpts.ClusterScopedKinds.
+func BuiltinClusterScopedKinds() []string {
+	kinds := make([]string, 0, len(clusterScopedKinds))
+	for kind := range clusterScopedKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	return kinds
+}
+
+func isClusterScoped(kind string) bool {
+	return clusterScopedKinds[strings.ToLower(kind)]
+}
+
+// namesNamespace returns false for the cluster-scoped kinds, which don't land in any namespace,
+// except for Namespace resources that name one
+func namesNamespace(kind string) bool {
+	return !isClusterScoped(kind) || strings.EqualFold(kind, "namespace")
+}
+
+// GetNamespaces returns the sorted list of namespaces referenced by all the resources of a manifest.
+// The name of Namespace resources is reported as well, while the other cluster-scoped resources are skipped.
+// Namespaced resources that do not specify a namespace are reported with an empty string so that callers
+// can detect resources landing in the default namespace.
+func GetNamespaces(manifestYaml []byte) ([]string, error) {
+	summary, err := Summarize(manifestYaml)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary.Namespaces, nil
+}
+
+// effectiveNamespace returns the namespace a resource is deployed to, which is the
+// default namespace for namespaced resources that don't specify one and empty for cluster-scoped resources
+func effectiveNamespace(resource map[string]interface{}) string {
+	if kind, _ := resource["kind"].(string); isClusterScoped(kind) {
+		return ""
+	}
+
+	if namespace := metadataString(resource, "namespace"); namespace != "" {
+		return namespace
+	}
+
+	return DefaultNamespace
+}
+
+// ValidateNamespaceName returns an error if name is not a valid namespace name, which must be an RFC 1123 label:
+// at most 63 lowercase alphanumeric characters or '-', starting and ending with an alphanumeric character
+func ValidateNamespaceName(name string) error {
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return errors.Errorf("invalid namespace name '%s': %s", name, strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// SetNamespace sets "Resource"->metadata->namespace to the given namespace on every namespaced resource of a manifest.
+// Cluster-scoped resources such as Namespace, ClusterRole or PersistentVolume are left untouched.
+// It returns an error without changing the manifest if namespace is not a valid namespace name.
+func SetNamespace(manifestYaml []byte, namespace string) ([]byte, error) {
+	if err := ValidateNamespaceName(namespace); err != nil {
+		return nil, err
+	}
+
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		setResourceNamespace(resource, namespace)
+		return nil
+	})
+}
+
+// SetNamespaceDeep sets the namespace of every namespaced resource of a manifest like SetNamespace, and also rewrites
+// the namespace references that would otherwise point at the former namespaces: the subjects[].namespace
+// of RoleBindings and ClusterRoleBindings, which reference the namespace of ServiceAccount subjects.
+// Subjects without a namespace are left untouched. Every other reference, such as the namespaces of webhook service
+// references, APIService services or Ingress backends in other namespaces, is not rewritten.
+func SetNamespaceDeep(manifestYaml []byte, namespace string) ([]byte, error) {
+	if err := ValidateNamespaceName(namespace); err != nil {
+		return nil, err
+	}
+
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		setResourceNamespace(resource, namespace)
+
+		if !matchesKind(resource, []string{"rolebinding", "clusterrolebinding"}) {
+			return nil
+		}
+
+		subjects, _ := resource["subjects"].([]interface{})
+		for _, s := range subjects {
+			if subject, ok := s.(map[string]interface{}); ok && subject["namespace"] != nil {
+				subject["namespace"] = namespace
+			}
+		}
+		return nil
+	})
+}
+
+// setResourceNamespace sets "Resource"->metadata->namespace to namespace unless the resource is cluster-scoped
+func setResourceNamespace(resource map[string]interface{}, namespace string) {
+	if kind, _ := resource["kind"].(string); isClusterScoped(kind) {
+		return
+	}
+
+	metadata, ok := resource["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = make(map[string]interface{})
+		resource["metadata"] = metadata
+	}
+	metadata["namespace"] = namespace
+}
+
+// FindNamespaceConflicts returns the namespaced resources of a manifest whose metadata.namespace is set to another
+// namespace than target, identified by their lowercase kind and name as kind/name in the order they are defined.
+// Resources that don't specify a namespace and cluster-scoped resources are not reported.
+func FindNamespaceConflicts(manifestYaml []byte, target string) ([]string, error) {
+	conflicts := make([]string, 0)
+
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		kind, _ := resource["kind"].(string)
+		if isClusterScoped(kind) {
+			return nil
+		}
+
+		if namespace := metadataString(resource, "namespace"); namespace != "" && namespace != target {
+			conflicts = append(conflicts, normalizeKind(kind)+"/"+resourceName(resource))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return conflicts, nil
+}
+
+// EnsureNamespaceDocument prepends a Namespace resource with the given name and labels to a manifest that doesn't
+// define it, so that the namespace gets created before the resources landing in it. The manifest is returned
+// unchanged if it already defines the namespace, otherwise it is kept as written after the prepended document.
+func EnsureNamespaceDocument(manifestYaml []byte, namespace string, labels map[string]string) ([]byte, error) {
+	if err := ValidateNamespaceName(namespace); err != nil {
+		return nil, err
+	}
+
+	if err := ValidateLabelKeys(labels); err != nil {
+		return nil, err
+	}
+
+	exists, err := ContainsResource(manifestYaml, "Namespace", namespace, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		return manifestYaml, nil
+	}
+
+	metadata := map[string]interface{}{"name": namespace}
+	if len(labels) > 0 {
+		metadata["labels"] = labels
+	}
+
+	doc, err := encodeDocument(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   metadata,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal namespace document")
+	}
+
+	if len(bytes.TrimSpace(manifestYaml)) == 0 {
+		return doc, nil
+	}
+
+	if DetectDocumentStyle(manifestYaml).LeadingSeparator {
+		return append(append([]byte("---\n"), doc...), manifestYaml...), nil
+	}
+
+	return append(append(doc, "---\n"...), manifestYaml...), nil
+}
@@ -0,0 +1,437 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GetNamespaces(t *testing.T) {
+	input := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: frontend
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: frontend
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: db
+      namespace: backend
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+kind: List
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: db
+  namespace: backend
+`
+
+	namespaces, err := GetNamespaces([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "backend", "frontend"}, namespaces)
+}
+
+func Test_GetNamespaces_ClusterScoped(t *testing.T) {
+	input := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: prod
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+apiVersion: v1
+kind: PersistentVolume
+metadata:
+  name: data
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+`
+
+	namespaces, err := GetNamespaces([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod"}, namespaces)
+}
+
+func Test_SetNamespace(t *testing.T) {
+	input := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: frontend
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+apiVersion: v1
+kind: PersistentVolume
+metadata:
+  name: data
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: frontend
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+  - apiVersion: rbac.authorization.k8s.io/v1
+    kind: ClusterRoleBinding
+    metadata:
+      name: reader
+kind: List
+`
+	expected := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: frontend
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+apiVersion: v1
+kind: PersistentVolume
+metadata:
+  name: data
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: target
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+      namespace: target
+  - apiVersion: rbac.authorization.k8s.io/v1
+    kind: ClusterRoleBinding
+    metadata:
+      name: reader
+kind: List
+`
+
+	result, err := SetNamespace([]byte(input), "target")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_ValidateNamespaceName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "frontend"},
+		{name: "team-1"},
+		{name: "1team"},
+		{name: strings.Repeat("a", 63)},
+		{name: strings.Repeat("a", 64), wantErr: true},
+		{name: "", wantErr: true},
+		{name: "My_Namespace", wantErr: true},
+		{name: "Frontend", wantErr: true},
+		{name: "-frontend", wantErr: true},
+		{name: "frontend-", wantErr: true},
+		{name: "front.end", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateNamespaceName(tt.name)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_SetNamespace_InvalidName(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+
+	_, err := SetNamespace([]byte(input), "My_Namespace")
+	assert.ErrorContains(t, err, "invalid namespace name 'My_Namespace'")
+}
+
+func Test_SetNamespaceDeep(t *testing.T) {
+	input := `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: runner
+  namespace: old
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: runner
+  namespace: old
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: runner
+subjects:
+  - kind: ServiceAccount
+    name: runner
+    namespace: old
+  - apiGroup: rbac.authorization.k8s.io
+    kind: Group
+    name: developers
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: runner-reader
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: reader
+subjects:
+  - kind: ServiceAccount
+    name: runner
+    namespace: old
+`
+	expected := `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: runner
+  namespace: new
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: runner
+  namespace: new
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: runner
+subjects:
+  - kind: ServiceAccount
+    name: runner
+    namespace: new
+  - apiGroup: rbac.authorization.k8s.io
+    kind: Group
+    name: developers
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: runner-reader
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: reader
+subjects:
+  - kind: ServiceAccount
+    name: runner
+    namespace: new
+`
+
+	result, err := SetNamespaceDeep([]byte(input), "new")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	// SetNamespace leaves the subjects untouched
+	result, err = SetNamespace([]byte(input), "new")
+	assert.NoError(t, err)
+	assert.Contains(t, string(result), "    name: runner\n    namespace: old\n")
+}
+
+func Test_FindNamespaceConflicts(t *testing.T) {
+	input := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: other
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: other
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: shop
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: credentials
+      namespace: default
+kind: List
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: reader
+  namespace: other
+`
+
+	conflicts, err := FindNamespaceConflicts([]byte(input), "shop")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"deployment/web", "secret/credentials"}, conflicts)
+
+	conflicts, err = FindNamespaceConflicts([]byte(input), "other")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"service/web", "secret/credentials"}, conflicts)
+}
+
+func Test_EnsureNamespaceDocument(t *testing.T) {
+	labels := map[string]string{"io.portainer.kubernetes.application.owner": "best-owner"}
+	namespaceDoc := `apiVersion: v1
+kind: Namespace
+metadata:
+  labels:
+    io.portainer.kubernetes.application.owner: best-owner
+  name: shop
+`
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name: "missing namespace is prepended",
+			input: `# the web service
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: shop
+`,
+			expected: namespaceDoc + `---
+# the web service
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: shop
+`,
+		},
+		{
+			name: "leading separator is kept",
+			input: `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`,
+			expected: "---\n" + namespaceDoc + `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`,
+		},
+		{
+			name: "other namespace doesn't count",
+			input: `apiVersion: v1
+kind: Namespace
+metadata:
+  name: other
+`,
+			expected: namespaceDoc + `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: other
+`,
+		},
+		{
+			name:     "empty manifest",
+			input:    "",
+			expected: namespaceDoc,
+		},
+		{
+			name: "existing namespace is left as is",
+			input: `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Namespace
+    metadata:
+      name: shop
+kind: List
+`,
+			expected: `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Namespace
+    metadata:
+      name: shop
+kind: List
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := EnsureNamespaceDocument([]byte(tt.input), "shop", labels)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(result))
+		})
+	}
+
+	t.Run("without labels", func(t *testing.T) {
+		result, err := EnsureNamespaceDocument(nil, "shop", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: shop\n", string(result))
+	})
+
+	t.Run("invalid namespace name", func(t *testing.T) {
+		_, err := EnsureNamespaceDocument(nil, "My_Namespace", nil)
+		assert.ErrorContains(t, err, "invalid namespace name")
+	})
+}
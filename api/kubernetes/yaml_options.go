@@ -0,0 +1,140 @@
+package kubernetes
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultMaxDocuments is the default maximum number of documents accepted in a manifest
+	DefaultMaxDocuments = 10000
+	// DefaultMaxDocumentBytes is the default maximum size of a single document in a manifest
+	DefaultMaxDocumentBytes = 10 << 20
+	// DefaultIndent is the default number of spaces documents are indented with when they are encoded
+	DefaultIndent = 2
+	// DefaultMaxDepth is the default maximum nesting depth of the maps and lists of a document
+	DefaultMaxDepth = 100
+)
+
+var (
+	// ErrTooManyDocuments is returned when a manifest holds more documents than allowed
+	ErrTooManyDocuments = errors.New("manifest exceeds the maximum number of documents")
+	// ErrDocumentTooLarge is returned when a document of a manifest is larger than allowed
+	ErrDocumentTooLarge = errors.New("document exceeds the maximum size")
+	// ErrMaxDepthExceeded is returned when the maps and lists of a document are nested deeper than allowed
+	ErrMaxDepthExceeded = errors.New("document exceeds the maximum nesting depth")
+)
+
+// ExtractOptions controls how the documents of a manifest are extracted
+type ExtractOptions struct {
+	// MaxDocuments is the maximum number of documents, including empty ones, accepted in a manifest.
+	// Defaults to DefaultMaxDocuments when zero, a negative value disables the limit.
+	MaxDocuments int
+	// MaxDocumentBytes is the maximum size in bytes of a single document of a manifest.
+	// Defaults to DefaultMaxDocumentBytes when zero, a negative value disables the limit.
+	MaxDocumentBytes int
+	// PreserveFormatting keeps the comments, key ordering, anchors and aliases and scalar styles (such as
+	// literal and folded block scalars) of the original documents instead of re-encoding them from scratch.
+	// The lines of folded block scalars may still be re-wrapped by the encoder, without changing their value.
+	// An alias whose value gets modified by post-processing is replaced by the modified value.
+	PreserveFormatting bool
+	// KeepEmptyDocuments returns an empty entry for each empty document of a manifest instead of skipping it,
+	// so that the documents of the output keep the positions they have in the manifest.
+	// A trailing "---" separator ends with an empty document. The elements of a top-level sequence are still
+	// returned as separate documents and shift the positions of the following ones.
+	KeepEmptyDocuments bool
+	// Strict fails on the resources holding fields that are unknown to their kind, such as a misspelled "metdata".
+	// The resources of the builtin kinds are checked against their whole definition, custom resources only have
+	// their metadata checked. Duplicate keys are rejected whether this is set or not.
+	Strict bool
+	// Indent is the number of spaces, between 2 and 9, documents are indented with when they are encoded.
+	// Defaults to DefaultIndent when zero.
+	Indent int
+	// MaxDepth is the maximum nesting depth of the maps and lists of a document, the content aliases bring in included.
+	// Defaults to DefaultMaxDepth when zero, a negative value disables the limit. The helpers that search documents
+	// for resources, such as AddAppLabelsWithOpts, don't search for them deeper than this either.
+	MaxDepth int
+}
+
+func (opts ExtractOptions) withDefaults() ExtractOptions {
+	if opts.MaxDocuments == 0 {
+		opts.MaxDocuments = DefaultMaxDocuments
+	}
+
+	if opts.MaxDocumentBytes == 0 {
+		opts.MaxDocumentBytes = DefaultMaxDocumentBytes
+	}
+
+	if opts.Indent == 0 {
+		opts.Indent = DefaultIndent
+	}
+
+	if opts.MaxDepth == 0 {
+		opts.MaxDepth = DefaultMaxDepth
+	}
+
+	return opts
+}
+
+// validate returns an error if opts hold values the yaml encoder doesn't support
+func (opts ExtractOptions) validate() error {
+	// the encoder silently falls back to 2 spaces for the other values
+	if opts.Indent != 0 && (opts.Indent < 2 || opts.Indent > 9) {
+		return errors.Errorf("indent must be between 2 and 9 spaces, got %d", opts.Indent)
+	}
+
+	return nil
+}
+
+// documentSizeReader fails reads once more than limit bytes have been read since the last reset.
+// The yaml decoder reads ahead in small chunks, so the size it measures for a document is approximate.
+type documentSizeReader struct {
+	r        io.Reader
+	limit    int
+	read     int
+	exceeded bool
+}
+
+func (r *documentSizeReader) Read(p []byte) (int, error) {
+	if r.limit > 0 && r.read > r.limit {
+		r.exceeded = true
+		return 0, ErrDocumentTooLarge
+	}
+
+	n, err := r.r.Read(p)
+	r.read += n
+	return n, err
+}
+
+func (r *documentSizeReader) reset() {
+	r.read = 0
+}
+
+// nodeDepth returns the nesting depth of the mappings and sequences of a yaml node, following aliases.
+// depths memoizes the depth of the nodes already measured so that nodes aliased several times are measured once.
+func nodeDepth(node *yaml.Node, depths map[*yaml.Node]int) int {
+	if depth, ok := depths[node]; ok {
+		return depth
+	}
+	// an anchor can't contain an alias to itself, this only guards against looping forever if it ever did
+	depths[node] = 0
+
+	depth := 0
+	switch node.Kind {
+	case yaml.AliasNode:
+		depth = nodeDepth(node.Alias, depths)
+	case yaml.DocumentNode, yaml.MappingNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			depth = max(depth, nodeDepth(child, depths))
+		}
+
+		if node.Kind != yaml.DocumentNode {
+			depth++
+		}
+	}
+
+	depths[node] = depth
+	return depth
+}
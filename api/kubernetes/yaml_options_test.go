@@ -0,0 +1,390 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExtractDocumentsWithOptions_Limits(t *testing.T) {
+	threeDocs := `apiVersion: v1
+kind: Service
+---
+apiVersion: v1
+kind: Service
+---
+apiVersion: v1
+kind: Service
+`
+
+	largeDoc := "apiVersion: v1\nkind: ConfigMap\ndata:\n  key: " + strings.Repeat("a", 4096) + "\n"
+
+	tests := []struct {
+		name    string
+		input   string
+		opts    ExtractOptions
+		wantErr error
+	}{
+		{
+			name:  "within the default limits",
+			input: threeDocs + "---\n" + largeDoc,
+		},
+		{
+			name:    "too many documents",
+			input:   threeDocs,
+			opts:    ExtractOptions{MaxDocuments: 2},
+			wantErr: ErrTooManyDocuments,
+		},
+		{
+			name:  "exactly the maximum number of documents",
+			input: threeDocs,
+			opts:  ExtractOptions{MaxDocuments: 3},
+		},
+		{
+			name:  "two documents with a limit of two",
+			input: "a: 1\n---\nb: 2\n",
+			opts:  ExtractOptions{MaxDocuments: 2},
+		},
+		{
+			name:    "too large document",
+			input:   threeDocs + "---\n" + largeDoc,
+			opts:    ExtractOptions{MaxDocumentBytes: 1024},
+			wantErr: ErrDocumentTooLarge,
+		},
+		{
+			name:  "disabled limits",
+			input: threeDocs + "---\n" + largeDoc,
+			opts:  ExtractOptions{MaxDocuments: -1, MaxDocumentBytes: -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ExtractDocumentsWithOptions([]byte(tt.input), nil, tt.opts)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.True(t, errors.Is(err, tt.wantErr), "unexpected error: %v", err)
+		})
+	}
+}
+
+func Test_ExtractDocumentsWithOptions_TooLargeDocumentIndex(t *testing.T) {
+	input := "apiVersion: v1\nkind: Service\n---\napiVersion: v1\nkind: ConfigMap\ndata:\n  key: " + strings.Repeat("a", 8192) + "\n"
+
+	_, err := ExtractDocumentsWithOptions([]byte(input), nil, ExtractOptions{MaxDocumentBytes: 2048})
+
+	var docErr *DocumentError
+	if assert.ErrorAs(t, err, &docErr) {
+		assert.Equal(t, 1, docErr.Index)
+	}
+}
+
+func Test_ExtractDocumentsWithOptions_KeepEmptyDocuments(t *testing.T) {
+	input := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+---
+# only a comment
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: second
+---
+`
+
+	t.Run("empty documents are skipped by default", func(t *testing.T) {
+		docs, err := ExtractDocumentsWithOptions([]byte(input), nil, ExtractOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, docs, 2)
+	})
+
+	for _, preserve := range []bool{false, true} {
+		t.Run(fmt.Sprintf("empty documents are kept, preserving formatting: %t", preserve), func(t *testing.T) {
+			docs, err := ExtractDocumentsWithOptions([]byte(input), nil, ExtractOptions{KeepEmptyDocuments: true, PreserveFormatting: preserve})
+			assert.NoError(t, err)
+
+			if assert.Len(t, docs, 5) {
+				assert.Contains(t, string(docs[0]), "name: first")
+				assert.Empty(t, docs[1])
+				assert.Empty(t, docs[2])
+				assert.Contains(t, string(docs[3]), "name: second")
+				assert.Empty(t, docs[4])
+			}
+		})
+	}
+}
+
+func Test_ExtractDocumentsWithOptions_Strict(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name: "valid builtin and custom resources",
+			input: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+        - image: nginx:1.25
+          name: web
+          resources:
+            limits:
+              cpu: 500m
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+spec:
+  anything: goes
+`,
+		},
+		{
+			name: "misspelled top-level field",
+			input: `apiVersion: v1
+kind: ConfigMap
+metdata:
+  name: config
+`,
+			wantErr: `unknown field "metdata"`,
+		},
+		{
+			name: "unknown nested field of a builtin kind",
+			input: `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - image: nginx:1.25
+          imagePullPolicy: Always
+          name: web
+          port: 80
+`,
+			wantErr: `document 1: invalid deployment/default/web: unknown field "spec.template.spec.containers[0].port"`,
+		},
+		{
+			name: "unknown metadata field of a custom resource",
+			input: `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+  lables:
+    app: web
+`,
+			wantErr: `unknown field "lables"`,
+		},
+		{
+			name: "list items are checked",
+			input: `apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: credentials
+    sringData:
+      password: secret
+`,
+			wantErr: `unknown field "sringData"`,
+		},
+		{
+			name: "wrong-case metadata field",
+			input: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  Labels:
+    app: web
+`,
+			wantErr: `unknown field "metadata.Labels"`,
+		},
+		{
+			name: "wrong-case field of a builtin kind",
+			input: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+Spec:
+  replicas: 2
+`,
+			wantErr: `unknown field "Spec"`,
+		},
+		{
+			name: "wrong-case metadata field of a custom resource",
+			input: `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  Name: widget
+`,
+			wantErr: `unknown field "Name"`,
+		},
+		{
+			name: "duplicate keys",
+			input: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  key: first
+  key: second
+`,
+			wantErr: `mapping key "key" already defined`,
+		},
+	}
+
+	for _, tt := range tests {
+		for _, preserve := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s, preserving formatting: %t", tt.name, preserve), func(t *testing.T) {
+				_, err := ExtractDocumentsWithOptions([]byte(tt.input), nil, ExtractOptions{Strict: true, PreserveFormatting: preserve})
+				if tt.wantErr == "" {
+					assert.NoError(t, err)
+					return
+				}
+
+				var docErr *DocumentError
+				assert.ErrorAs(t, err, &docErr)
+				assert.ErrorContains(t, err, tt.wantErr)
+			})
+		}
+	}
+
+	t.Run("unknown fields are accepted by default", func(t *testing.T) {
+		_, err := ExtractDocumentsWithOptions([]byte("apiVersion: v1\nkind: ConfigMap\nmetdata:\n  name: config\n"), nil, ExtractOptions{})
+		assert.NoError(t, err)
+	})
+}
+
+func Test_ExtractDocumentsWithOptions_Indent(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - image: nginx:1.25
+          name: web
+`
+
+	expected := map[int]string{
+		2: input,
+		4: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+    name: web
+spec:
+    template:
+        spec:
+            containers:
+                - image: nginx:1.25
+                  name: web
+`,
+	}
+
+	for indent, want := range expected {
+		for _, preserve := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%d spaces, preserving formatting: %t", indent, preserve), func(t *testing.T) {
+				docs, err := ExtractDocumentsWithOptions([]byte(input), nil, ExtractOptions{Indent: indent, PreserveFormatting: preserve})
+				assert.NoError(t, err)
+				assert.Equal(t, []string{want}, toStrings(docs))
+			})
+		}
+	}
+
+	t.Run("unsupported indent", func(t *testing.T) {
+		_, err := ExtractDocumentsWithOptions([]byte(input), nil, ExtractOptions{Indent: 1})
+		assert.ErrorContains(t, err, "indent must be between 2 and 9 spaces, got 1")
+	})
+}
+
+func Test_AddAppLabelsWithOpts_Indent(t *testing.T) {
+	input := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+	for indent, want := range map[int]string{
+		0: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  labels:\n    io.portainer.kubernetes.application.name: best-name\n    io.portainer.kubernetes.application.owner: best-owner\n  name: config\n",
+		4: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n    labels:\n        io.portainer.kubernetes.application.name: best-name\n        io.portainer.kubernetes.application.owner: best-owner\n    name: config\n",
+	} {
+		result, err := AddAppLabelsWithOpts([]byte(input), GetHelmAppLabels("best-name", "best-owner"), AddAppLabelsOpts{Indent: indent})
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(result))
+	}
+}
+
+func Test_ExtractDocumentsWithOptions_MaxDepth(t *testing.T) {
+	// nested returns a document whose maps are nested depth levels deep
+	nested := func(depth int) string {
+		return "kind: ConfigMap\ndata: " + strings.Repeat("{a: ", depth-1) + "b" + strings.Repeat("}", depth-1) + "\n"
+	}
+
+	t.Run("document at the limit", func(t *testing.T) {
+		_, err := ExtractDocumentsWithOptions([]byte(nested(DefaultMaxDepth)), nil, ExtractOptions{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("document past the limit", func(t *testing.T) {
+		_, err := ExtractDocumentsWithOptions([]byte("kind: Service\n---\n"+nested(DefaultMaxDepth+1)), nil, ExtractOptions{})
+		assert.ErrorIs(t, err, ErrMaxDepthExceeded)
+
+		var docErr *DocumentError
+		if assert.ErrorAs(t, err, &docErr) {
+			assert.Equal(t, 1, docErr.Index)
+		}
+	})
+
+	t.Run("custom limit", func(t *testing.T) {
+		_, err := ExtractDocumentsWithOptions([]byte(nested(10)), nil, ExtractOptions{MaxDepth: 5})
+		assert.ErrorIs(t, err, ErrMaxDepthExceeded)
+	})
+
+	t.Run("depth brought in by aliases", func(t *testing.T) {
+		input := "kind: ConfigMap\nanchor: &deep " + strings.Repeat("{a: ", 59) + "b" + strings.Repeat("}", 59) +
+			"\ndata: " + strings.Repeat("{a: ", 59) + "*deep" + strings.Repeat("}", 59) + "\n"
+
+		_, err := ExtractDocumentsWithOptions([]byte(input), nil, ExtractOptions{})
+		assert.ErrorIs(t, err, ErrMaxDepthExceeded)
+	})
+
+	t.Run("resources are not searched for past the limit", func(t *testing.T) {
+		input := "items: " + strings.Repeat("{a: ", DefaultMaxDepth+50) + "b" + strings.Repeat("}", DefaultMaxDepth+50) + "\n"
+
+		_, err := ExtractDocumentsWithOptions([]byte(input), func(doc interface{}) error {
+			return addResourceLabels(doc, GetHelmAppLabels("best-name", "best-owner"))
+		}, ExtractOptions{MaxDepth: -1})
+		assert.ErrorIs(t, err, ErrMaxDepthExceeded)
+	})
+}
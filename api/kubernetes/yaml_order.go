@@ -0,0 +1,78 @@
+package kubernetes
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// applyRank returns the position of a resource kind in the apply order of OrderResourcesForApply, lower first
+func applyRank(kind string) int {
+	switch normalizeKind(kind) {
+	case "namespace":
+		return 0
+	case "customresourcedefinition":
+		return 1
+	}
+
+	if isClusterScoped(kind) {
+		return 2
+	}
+
+	switch normalizeKind(kind) {
+	case "configmap", "secret":
+		return 3
+	case "pod", "replicationcontroller", "deployment", "statefulset", "daemonset", "replicaset", "job", "cronjob":
+		return 5
+	}
+
+	return 4
+}
+
+// OrderResourcesForApply re-emits the resources of a manifest in an order that can be applied to a fresh cluster
+// without referencing resources that don't exist yet:
+//  1. Namespaces, which namespaced resources are created in
+//  2. CustomResourceDefinitions, which custom resources are instances of
+//  3. the other cluster-scoped resources, such as ClusterRoles or StorageClasses
+//  4. ConfigMaps and Secrets, which pods mount or read their environment from
+//  5. everything else, such as Services, ServiceAccounts or custom resources
+//  6. the workloads creating pods: Pods, ReplicationControllers, Deployments, StatefulSets, DaemonSets,
+//     ReplicaSets, Jobs and CronJobs
+//
+// Resources keep their relative order within each group. Every resource is emitted as its own document,
+// so the items of a list are ordered individually and the list itself is dropped.
+func OrderResourcesForApply(manifestYaml []byte) ([]byte, error) {
+	type ranked struct {
+		rank int
+		out  []byte
+	}
+
+	resources := make([]ranked, 0)
+
+	err := forEachDocument(manifestYaml, func(index int, doc map[string]interface{}) error {
+		return visitResources(doc, func(resource map[string]interface{}) error {
+			out, err := encodeDocument(resource)
+			if err != nil {
+				return errors.Wrap(newDocumentError(index, err), "failed to marshal yaml manifest")
+			}
+
+			kind, _ := resource["kind"].(string)
+			resources = append(resources, ranked{rank: applyRank(kind), out: out})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(resources, func(i, j int) bool {
+		return resources[i].rank < resources[j].rank
+	})
+
+	docs := make([][]byte, 0, len(resources))
+	for _, resource := range resources {
+		docs = append(docs, resource.out)
+	}
+
+	return JoinDocuments(docs, DetectDocumentStyle(manifestYaml)), nil
+}
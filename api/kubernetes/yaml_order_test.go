@@ -0,0 +1,92 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OrderResourcesForApply(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shop
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+  namespace: shop
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+      namespace: shop
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: credentials
+      namespace: shop
+kind: List
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: shop
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+  namespace: shop
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: shop
+`
+
+	result, err := OrderResourcesForApply([]byte(input))
+	assert.NoError(t, err)
+
+	refs, err := ListResources(result)
+	assert.NoError(t, err)
+
+	order := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		order = append(order, ref.Kind+"/"+ref.Name)
+	}
+
+	assert.Equal(t, []string{
+		"Namespace/shop",
+		"CustomResourceDefinition/widgets.example.com",
+		"ClusterRole/reader",
+		"Secret/credentials",
+		"ConfigMap/config",
+		"Widget/widget",
+		"Service/web",
+		"Deployment/web",
+		"CronJob/backup",
+	}, order)
+}
+
+func Test_OrderResourcesForApply_Empty(t *testing.T) {
+	result, err := OrderResourcesForApply([]byte(""))
+	assert.NoError(t, err)
+	assert.Empty(t, result)
+}
@@ -0,0 +1,106 @@
+package kubernetes
+
+import (
+	"bytes"
+	"runtime"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ExtractDocumentsParallel extracts all the documents from a yaml file like ExtractDocuments, post-processing
+// and re-encoding them across workers goroutines while keeping the documents in order. The manifest is parsed
+// sequentially beforehand, and a workers value lower than 1 uses one worker per available CPU.
+// postProcess is called concurrently and must be safe for it. The errors of all the failed documents are returned together
+// as DocumentErrors when there are several of them.
+// The documents are extracted with the default ExtractOptions: the default document count, size and depth limits apply,
+// empty documents are skipped and the documents are re-encoded from scratch. A gzip-compressed manifest is decompressed first.
+func ExtractDocumentsParallel(manifestYaml []byte, postProcess func(interface{}) error, workers int) ([][]byte, error) {
+	type document struct {
+		index int
+		node  *yaml.Node
+	}
+
+	manifestYaml, err := decompressManifest(manifestYaml)
+	if err != nil {
+		return nil, err
+	}
+
+	// the yaml decoder is stateful so parsing can't be spread across workers,
+	// converting the parsed nodes to maps can
+	documents := make([]document, 0)
+	err = decodeNodes(bytes.NewReader(manifestYaml), ExtractOptions{}, func(index int, doc *yaml.Node) error {
+		for _, node := range resourceNodes(doc) {
+			documents = append(documents, document{index: index, node: node})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	results := make([][]byte, len(documents))
+	errs := make([]error, len(documents))
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(documents)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i], errs[i] = processDocumentNodeToMap(documents[i].index, documents[i].node, postProcess)
+			}
+		}()
+	}
+
+	for i := range documents {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	failed := make(DocumentErrors, 0)
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	if len(failed) == 1 {
+		return nil, failed[0]
+	}
+	if len(failed) > 1 {
+		return nil, failed
+	}
+
+	// empty documents have no result
+	docs := make([][]byte, 0, len(results))
+	for _, out := range results {
+		if out != nil {
+			docs = append(docs, out)
+		}
+	}
+
+	return docs, nil
+}
+
+// processDocumentNodeToMap decodes a parsed document into a map and processes it like processDocument,
+// it returns nil for an empty document
+func processDocumentNodeToMap(index int, node *yaml.Node, postProcess func(interface{}) error) ([]byte, error) {
+	var m map[string]interface{}
+	if err := node.Decode(&m); err != nil {
+		return nil, errors.Wrap(newDocumentError(index, err), "failed to unmarshal yaml manifest")
+	}
+
+	if m == nil {
+		return nil, nil
+	}
+
+	return processDocument(index, m, postProcess, DefaultIndent)
+}
@@ -0,0 +1,118 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExtractDocumentsParallel(t *testing.T) {
+	input := generateManifest(50)
+	labels := GetHelmAppLabels("best-name", "best-owner")
+	postProcess := func(doc interface{}) error {
+		return addResourceLabels(doc, labels)
+	}
+
+	expected, err := ExtractDocuments(input, postProcess)
+	assert.NoError(t, err)
+
+	for _, workers := range []int{0, 1, 4, 100} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			docs, err := ExtractDocumentsParallel(input, postProcess, workers)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, docs)
+		})
+	}
+}
+
+func Test_ExtractDocumentsParallel_Errors(t *testing.T) {
+	input := generateManifest(10)
+
+	_, err := ExtractDocumentsParallel(input, func(doc interface{}) error {
+		name := doc.(map[string]interface{})["metadata"].(map[string]interface{})["name"].(string)
+		if name == "web-3" || name == "web-7" {
+			return errors.New("boom")
+		}
+		return nil
+	}, 4)
+
+	var docErr *DocumentError
+	assert.ErrorAs(t, err, &docErr)
+	assert.ErrorContains(t, err, "document 3")
+	assert.ErrorContains(t, err, "document 7")
+
+	var docErrs DocumentErrors
+	if assert.ErrorAs(t, err, &docErrs) {
+		assert.Len(t, docErrs, 2)
+	}
+}
+
+func Test_ExtractDocumentsParallel_Gzip(t *testing.T) {
+	input := generateManifest(10)
+
+	expected, err := ExtractDocuments(input, nil)
+	assert.NoError(t, err)
+
+	docs, err := ExtractDocumentsParallel(gzipManifest(t, input), nil, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, docs)
+}
+
+func Benchmark_ExtractDocuments(b *testing.B) {
+	input := generateManifest(2000)
+	postProcess := benchmarkPostProcess()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ExtractDocuments(input, postProcess); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_ExtractDocumentsParallel(b *testing.B) {
+	input := generateManifest(2000)
+	postProcess := benchmarkPostProcess()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ExtractDocumentsParallel(input, postProcess, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkPostProcess() func(interface{}) error {
+	labels := GetHelmAppLabels("best-name", "best-owner")
+	return func(doc interface{}) error {
+		return addResourceLabels(doc, labels)
+	}
+}
+
+// generateManifest returns a manifest made of count deployments named web-<index>
+func generateManifest(count int) []byte {
+	var sb strings.Builder
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&sb, `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web-%d
+spec:
+  replicas: 2
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+        - image: nginx:1.25
+          name: web
+`, i)
+	}
+
+	return []byte(sb.String())
+}
@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"github.com/pkg/errors"
+)
+
+// PatchResources merges patch into every resource of a manifest whose kind matches kind (case-insensitively),
+// following the JSON merge patch semantics: maps are merged recursively, other values such as scalars
+// and arrays replace the existing ones, and a null value removes the key. The other resources are left as they are.
+func PatchResources(manifestYaml []byte, kind string, patch map[string]interface{}) ([]byte, error) {
+	if kind == "" {
+		return nil, errors.New("a kind is required to patch resources")
+	}
+
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		if matchesKind(resource, []string{kind}) {
+			mergePatch(resource, patch)
+		}
+		return nil
+	})
+}
+
+// mergePatch merges patch into target in place
+func mergePatch(target, patch map[string]interface{}) {
+	for k, v := range patch {
+		if v == nil {
+			delete(target, k)
+			continue
+		}
+
+		patchMap, ok := toStringMap(v)
+		if !ok {
+			target[k] = copyPatchValue(v)
+			continue
+		}
+
+		existing, ok := target[k].(map[string]interface{})
+		if !ok {
+			// merging into an empty map drops the null values of the patch
+			existing = make(map[string]interface{})
+			target[k] = existing
+		}
+		mergePatch(existing, patchMap)
+	}
+}
+
+// copyPatchValue returns a deep copy of a patch value so that resources patched with it don't share it
+func copyPatchValue(v interface{}) interface{} {
+	if m, ok := toStringMap(v); ok {
+		copied := make(map[string]interface{}, len(m))
+		mergePatch(copied, m)
+		return copied
+	}
+
+	if s, ok := v.([]interface{}); ok {
+		copied := make([]interface{}, len(s))
+		for i, item := range s {
+			copied[i] = copyPatchValue(item)
+		}
+		return copied
+	}
+
+	return v
+}
+
+// toStringMap returns v as a map[string]interface{}, converting the map[string]string values callers often build
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[string]string:
+		converted := make(map[string]interface{}, len(m))
+		for k, value := range m {
+			converted[k] = value
+		}
+		return converted, true
+	}
+
+	return nil, false
+}
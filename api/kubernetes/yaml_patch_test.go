@@ -0,0 +1,129 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PatchResources(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+  template:
+    metadata:
+      annotations:
+        existing: value
+        obsolete: value
+      labels:
+        app: web
+    spec:
+      containers:
+        - image: nginx:1.25
+          name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  replicas: 2
+`
+
+	patch := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": 3,
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						"policy.example.com/audited": "true",
+						"obsolete":                   nil,
+					},
+					"labels": map[string]string{
+						"tier": "frontend",
+					},
+				},
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"image": "nginx:1.26", "name": "web"},
+					},
+					"securityContext": map[string]interface{}{"runAsNonRoot": true},
+				},
+			},
+		},
+	}
+
+	expected := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    metadata:
+      annotations:
+        existing: value
+        policy.example.com/audited: "true"
+      labels:
+        app: web
+        tier: frontend
+    spec:
+      containers:
+        - image: nginx:1.26
+          name: web
+      securityContext:
+        runAsNonRoot: true
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  replicas: 2
+`
+
+	result, err := PatchResources([]byte(input), "deployment", patch)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_PatchResources_ListItemsDontSharePatchValues(t *testing.T) {
+	input := `apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: first
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: second
+kind: List
+`
+
+	patch := map[string]interface{}{"data": map[string]interface{}{"key": "value"}}
+
+	result, err := PatchResources([]byte(input), "ConfigMap", patch)
+	assert.NoError(t, err)
+
+	expected := `apiVersion: v1
+items:
+  - apiVersion: v1
+    data:
+      key: value
+    kind: ConfigMap
+    metadata:
+      name: first
+  - apiVersion: v1
+    data:
+      key: value
+    kind: ConfigMap
+    metadata:
+      name: second
+kind: List
+`
+	assert.Equal(t, expected, string(result))
+}
@@ -0,0 +1,160 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/portainer/portainer/api/kubernetes/validation"
+
+	"github.com/pkg/errors"
+)
+
+// PrefixResourceNames prepends prefix to the name of every resource of a manifest so that the same stack
+// can be deployed several times in a namespace. Resources that only set metadata.generateName get it prefixed instead.
+//
+// To keep the instances apart, the values of the workload selectors (spec.selector.matchLabels) and of the Service
+// selectors (spec.selector) are prefixed as well, along with the pod template labels of every key either of them
+// selects on and the labels of bare Pods of every key a Service selects on, so a Service keeps selecting
+// the pods of its own instance. The other references are not rewritten and keep pointing to the
+// unprefixed names: selector matchExpressions, ConfigMap, Secret and PersistentVolumeClaim references of pods,
+// service accounts, Ingress backends, StatefulSet service names, RBAC subjects and role references,
+// and scale or owner references.
+//
+// It fails without changing the manifest if the prefix is not made of lowercase alphanumeric characters or '-',
+// or if it makes a name or a label value invalid, for instance by exceeding the 63-character limit.
+func PrefixResourceNames(manifestYaml []byte, prefix string) ([]byte, error) {
+	if strings.TrimSpace(prefix) == "" {
+		return nil, errors.New("resource name prefix cannot be empty")
+	}
+
+	// a name follows the prefix, which may end with a '-' separator
+	if errs := validation.IsDNS1123Label(strings.TrimRight(prefix, "-")); len(errs) > 0 {
+		return nil, errors.Errorf("invalid resource name prefix '%s': %s", prefix, strings.Join(errs, ", "))
+	}
+
+	manifestYaml, err := decompressManifest(manifestYaml)
+	if err != nil {
+		return nil, err
+	}
+
+	// the keys Services select pods on, the pod template labels need the same values as the selectors
+	serviceSelectorKeys := make(map[string]interface{})
+	err = WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		if kind, _ := resource["kind"].(string); strings.EqualFold(kind, "service") {
+			selector, _ := nestedMap(resource, "spec", "selector")
+			for k := range selector {
+				serviceSelectorKeys[k] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		if err := prefixName(resource, prefix); err != nil {
+			return err
+		}
+
+		kind, _ := resource["kind"].(string)
+		if strings.EqualFold(kind, "service") {
+			if selector, ok := nestedMap(resource, "spec", "selector"); ok {
+				return prefixValues(resource, selector, prefix, nil)
+			}
+			return nil
+		}
+
+		// bare pods are selected by Services on their own labels
+		if strings.EqualFold(kind, "pod") {
+			if labels, ok := nestedMap(resource, "metadata", "labels"); ok {
+				return prefixValues(resource, labels, prefix, serviceSelectorKeys)
+			}
+			return nil
+		}
+
+		template, ok := podTemplate(resource)
+		if !ok {
+			return nil
+		}
+
+		selectedKeys := make(map[string]interface{}, len(serviceSelectorKeys))
+		for k := range serviceSelectorKeys {
+			selectedKeys[k] = true
+		}
+
+		matchLabels, hasMatchLabels := nestedMap(resource, "spec", "selector", "matchLabels")
+		for k := range matchLabels {
+			selectedKeys[k] = true
+		}
+
+		if labels, ok := nestedMap(template, "metadata", "labels"); ok {
+			if err := prefixValues(resource, labels, prefix, selectedKeys); err != nil {
+				return err
+			}
+		}
+		if hasMatchLabels {
+			return prefixValues(resource, matchLabels, prefix, nil)
+		}
+
+		return nil
+	})
+}
+
+// prefixName prepends prefix to "Resource"->metadata->name, or to metadata.generateName when there is no name.
+// It fails if the prefixed name is not a valid name, Services and Namespaces need a DNS label and the other
+// resources a DNS subdomain.
+func prefixName(resource map[string]interface{}, prefix string) error {
+	metadata, ok := resource["metadata"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	for _, field := range []string{"name", "generateName"} {
+		name, ok := metadata[field].(string)
+		if !ok || name == "" {
+			continue
+		}
+
+		// a generated name is completed with a random suffix, stand in for it to validate the name
+		prefixed := prefix + name
+		if field == "generateName" {
+			prefixed += "x"
+		}
+
+		validate := validation.IsDNS1123Subdomain
+		if matchesKind(resource, []string{"service", "namespace"}) {
+			validate = validation.IsDNS1123Label
+		}
+
+		if errs := validate(prefixed); len(errs) > 0 {
+			return errors.Errorf("prefixing the %s of %s with '%s' makes it invalid: %s", field, resourceKey(resource), prefix, strings.Join(errs, ", "))
+		}
+
+		metadata[field] = prefix + name
+		return nil
+	}
+
+	return nil
+}
+
+// prefixValues prepends prefix to the values of the labels of resource, only for the keys of onlyKeys when it is
+// not nil. It fails if a prefixed value is not a valid label value.
+func prefixValues(resource, labels map[string]interface{}, prefix string, onlyKeys map[string]interface{}) error {
+	for k, v := range labels {
+		if onlyKeys != nil {
+			if _, ok := onlyKeys[k]; !ok {
+				continue
+			}
+		}
+
+		value := prefix + fmt.Sprintf("%v", v)
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return errors.Errorf("prefixing the value of label '%s' of %s with '%s' makes it invalid: %s", k, resourceKey(resource), prefix, strings.Join(errs, ", "))
+		}
+
+		labels[k] = value
+	}
+
+	return nil
+}
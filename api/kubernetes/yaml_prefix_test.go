@@ -0,0 +1,249 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PrefixResourceNames(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: web
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+        tier: frontend
+    spec:
+      containers:
+        - envFrom:
+            - configMapRef:
+                name: web-config
+          image: nginx:1.25
+          name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+    - port: 80
+  selector:
+    app: web
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  generateName: migrate-
+`
+	expected := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: web
+  name: blue-web
+spec:
+  selector:
+    matchLabels:
+      app: blue-web
+  template:
+    metadata:
+      labels:
+        app: blue-web
+        tier: frontend
+    spec:
+      containers:
+        - envFrom:
+            - configMapRef:
+                name: web-config
+          image: nginx:1.25
+          name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: blue-web
+spec:
+  ports:
+    - port: 80
+  selector:
+    app: blue-web
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  generateName: blue-migrate-
+`
+
+	result, err := PrefixResourceNames([]byte(input), "blue-")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_PrefixResourceNames_InvalidPrefix(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web-application-with-a-rather-long-name-already
+  template:
+    metadata:
+      labels:
+        app: web-application-with-a-rather-long-name-already
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: web-application-with-a-rather-long-name-already
+`
+
+	tests := []struct {
+		name    string
+		prefix  string
+		wantErr string
+	}{
+		{
+			name:    "invalid characters",
+			prefix:  "Bad_",
+			wantErr: "invalid resource name prefix 'Bad_'",
+		},
+		{
+			name:    "only a separator",
+			prefix:  "-",
+			wantErr: "invalid resource name prefix '-'",
+		},
+		{
+			name:    "name exceeding the DNS label limit",
+			prefix:  strings.Repeat("a", 62) + "-",
+			wantErr: "makes it invalid",
+		},
+		{
+			name:    "label value exceeding 63 characters",
+			prefix:  "blue-green-canary-",
+			wantErr: "prefixing the value of label 'app'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := PrefixResourceNames([]byte(input), tt.prefix)
+			assert.ErrorContains(t, err, tt.wantErr)
+			assert.Nil(t, result)
+		})
+	}
+}
+
+func Test_PrefixResourceNames_ServiceSelectorKeys(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: web
+    tier: frontend
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+        tier: frontend
+        version: v1
+`
+	expected := `apiVersion: v1
+kind: Service
+metadata:
+  name: blue-web
+spec:
+  selector:
+    app: blue-web
+    tier: blue-frontend
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: blue-web
+spec:
+  selector:
+    matchLabels:
+      app: blue-web
+  template:
+    metadata:
+      labels:
+        app: blue-web
+        tier: blue-frontend
+        version: v1
+`
+
+	result, err := PrefixResourceNames([]byte(input), "blue-")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_PrefixResourceNames_Pod(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: web
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  labels:
+    app: web
+    version: v1
+  name: web
+`
+	expected := `apiVersion: v1
+kind: Service
+metadata:
+  name: i1-web
+spec:
+  selector:
+    app: i1-web
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  labels:
+    app: i1-web
+    version: v1
+  name: i1-web
+`
+
+	result, err := PrefixResourceNames([]byte(input), "i1-")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_PrefixResourceNames_EmptyPrefix(t *testing.T) {
+	_, err := PrefixResourceNames([]byte("kind: Service\n"), "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,292 @@
+package kubernetes
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ExtractDocumentsPreserving extracts all the documents from a yaml file like ExtractDocuments does,
+// but keeps the comments, key ordering and formatting of the original documents.
+// postProcessYaml receives the same map representation as with ExtractDocuments,
+// the changes it makes are then reconciled into the original yaml tree before it gets encoded.
+func ExtractDocumentsPreserving(manifestYaml []byte, postProcessYaml func(interface{}) error) ([][]byte, error) {
+	return ExtractDocumentsWithOptions(manifestYaml, postProcessYaml, ExtractOptions{PreserveFormatting: true})
+}
+
+func extractDocumentsPreserving(manifestYaml []byte, postProcessYaml func(interface{}) error, opts ExtractOptions) ([][]byte, error) {
+	docs := make([][]byte, 0)
+
+	err := decodeNodes(bytes.NewReader(manifestYaml), opts, func(index int, doc *yaml.Node) error {
+		count := len(docs)
+		for _, node := range resourceNodes(doc) {
+			if opts.Strict {
+				var m map[string]interface{}
+				if err := node.Decode(&m); err != nil {
+					return errors.Wrap(newDocumentError(index, err), "failed to unmarshal yaml manifest")
+				}
+
+				if err := validateStrict(m); err != nil {
+					return errors.Wrap(newDocumentError(index, err), "strict validation failed")
+				}
+			}
+
+			out, err := processDocumentNode(index, node, postProcessYaml, opts.Indent)
+			if err != nil {
+				return err
+			}
+
+			if out != nil {
+				docs = append(docs, out)
+			}
+		}
+
+		if opts.KeepEmptyDocuments && len(docs) == count {
+			docs = append(docs, []byte{})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// transformResourcesPreserving is the PreserveFormatting counterpart of transformResourcesWithOptions, it returns
+// the documents that are kept
+func transformResourcesPreserving(manifestYaml []byte, opts ExtractOptions, transform func(resource map[string]interface{}) (bool, error)) ([][]byte, error) {
+	docs := make([][]byte, 0)
+
+	err := decodeNodes(bytes.NewReader(manifestYaml), opts, func(index int, doc *yaml.Node) error {
+		for _, node := range resourceNodes(doc) {
+			keep := true
+			out, err := processDocumentNode(index, node, func(yamlDoc interface{}) error {
+				var err error
+				keep, err = filterResources(yamlDoc, opts.MaxDepth, transform)
+				return err
+			}, opts.Indent)
+			if err != nil {
+				return err
+			}
+
+			if out != nil && keep {
+				docs = append(docs, out)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// processDocumentNode optionally post-processes a decoded document node, preserving its formatting, and encodes it
+// indented with indent spaces. It returns nil if the document is empty.
+func processDocumentNode(index int, node *yaml.Node, postProcessYaml func(interface{}) error, indent int) ([]byte, error) {
+	var m map[string]interface{}
+	if err := node.Decode(&m); err != nil {
+		return nil, errors.Wrap(newDocumentError(index, err), "failed to unmarshal yaml manifest")
+	}
+
+	// if decoded document is empty
+	if m == nil {
+		return nil, nil
+	}
+
+	// optionally post-process yaml
+	if postProcessYaml != nil {
+		if err := postProcessYaml(m); err != nil {
+			return nil, errors.Wrap(newDocumentError(index, err), "failed to post process yaml document")
+		}
+
+		if err := syncDocumentNode(node, m); err != nil {
+			return nil, errors.Wrap(newDocumentError(index, err), "failed to update yaml document")
+		}
+	}
+
+	untagMergeKeys(node)
+
+	var out bytes.Buffer
+	yamlEncoder := yaml.NewEncoder(&out)
+	yamlEncoder.SetIndent(indent)
+	if err := yamlEncoder.Encode(node); err != nil {
+		return nil, errors.Wrap(newDocumentError(index, err), "failed to marshal yaml manifest")
+	}
+
+	return out.Bytes(), nil
+}
+
+// syncDocumentNode updates the yaml tree of a document, or of a node processed as a document,
+// so that it represents m, only touching the nodes whose value has changed
+func syncDocumentNode(doc *yaml.Node, m map[string]interface{}) error {
+	// round-trip the post-processed document so that its values have the types
+	// the yaml decoder produces, e.g. map[string]string becomes map[string]interface{}
+	raw, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	var value interface{}
+	if err := yaml.Unmarshal(raw, &value); err != nil {
+		return err
+	}
+
+	if doc.Kind == yaml.DocumentNode {
+		return syncNode(doc.Content[0], value)
+	}
+
+	return syncNode(doc, value)
+}
+
+func syncNode(node *yaml.Node, value interface{}) error {
+	var current interface{}
+	if err := node.Decode(&current); err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(current, value) {
+		return nil
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		if m, ok := value.(map[string]interface{}); ok {
+			return syncMappingNode(node, current, m)
+		}
+	case yaml.SequenceNode:
+		if s, ok := value.([]interface{}); ok {
+			return syncSequenceNode(node, s)
+		}
+	}
+
+	return replaceNode(node, value)
+}
+
+func syncMappingNode(node *yaml.Node, current interface{}, m map[string]interface{}) error {
+	currentMap, _ := current.(map[string]interface{})
+
+	explicit := make(map[string]bool)
+	content := make([]*yaml.Node, 0, len(node.Content))
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+
+		// merge keys are kept as they are, the keys they bring in are handled below
+		if keyNode.Tag == "!!merge" {
+			content = append(content, keyNode, valueNode)
+			continue
+		}
+
+		v, ok := m[keyNode.Value]
+		if !ok {
+			continue
+		}
+
+		explicit[keyNode.Value] = true
+		if err := syncNode(valueNode, v); err != nil {
+			return err
+		}
+		content = append(content, keyNode, valueNode)
+	}
+
+	added := make([]string, 0)
+	for k, v := range m {
+		if explicit[k] {
+			continue
+		}
+
+		// keys inherited through a merge key are only written out when they change
+		if inherited, ok := currentMap[k]; ok && reflect.DeepEqual(inherited, v) {
+			continue
+		}
+
+		added = append(added, k)
+	}
+	sort.Strings(added)
+
+	for _, k := range added {
+		var keyNode, valueNode yaml.Node
+		if err := keyNode.Encode(k); err != nil {
+			return err
+		}
+		if err := valueNode.Encode(m[k]); err != nil {
+			return err
+		}
+		content = append(content, &keyNode, &valueNode)
+	}
+
+	node.Content = content
+	return nil
+}
+
+func syncSequenceNode(node *yaml.Node, s []interface{}) error {
+	if len(s) < len(node.Content) {
+		node.Content = node.Content[:len(s)]
+	}
+
+	for i, v := range s {
+		if i < len(node.Content) {
+			if err := syncNode(node.Content[i], v); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var item yaml.Node
+		if err := item.Encode(v); err != nil {
+			return err
+		}
+		node.Content = append(node.Content, &item)
+	}
+
+	return nil
+}
+
+// replaceNode replaces the content of node with value, keeping its comments and anchor
+func replaceNode(node *yaml.Node, value interface{}) error {
+	var replacement yaml.Node
+	if err := replacement.Encode(value); err != nil {
+		return err
+	}
+
+	// keep block scalars in their original style as long as the new value still spans multiple lines
+	if replacement.Kind == yaml.ScalarNode && replacement.Tag == "!!str" && strings.Contains(replacement.Value, "\n") &&
+		(node.Style&(yaml.LiteralStyle|yaml.FoldedStyle)) != 0 {
+		replacement.Style = node.Style & (yaml.LiteralStyle | yaml.FoldedStyle)
+	}
+
+	replacement.Anchor = node.Anchor
+	replacement.HeadComment = node.HeadComment
+	replacement.LineComment = node.LineComment
+	replacement.FootComment = node.FootComment
+
+	*node = replacement
+	return nil
+}
+
+// untagMergeKeys clears the tag of merge keys, which the encoder would otherwise write out explicitly as "!!merge <<"
+func untagMergeKeys(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Tag == "!!merge" {
+				node.Content[i].Tag = ""
+			}
+		}
+	}
+
+	if node.Kind == yaml.AliasNode {
+		return
+	}
+
+	for _, child := range node.Content {
+		untagMergeKeys(child)
+	}
+}
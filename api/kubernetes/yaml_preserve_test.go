@@ -0,0 +1,207 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExtractDocumentsPreserving(t *testing.T) {
+	labels := GetHelmAppLabels("best-name", "best-owner")
+
+	input := `# web frontend
+apiVersion: v1
+kind: Service
+metadata:
+  name: web # the service name
+  labels:
+    app: web
+spec:
+  # exposed ports
+  ports:
+    - port: 80
+      targetPort: 8080
+  selector:
+    app: web
+---
+kind: ConfigMap
+apiVersion: v1
+metadata:
+  name: config
+data:
+  key: "value"
+`
+	expected := []string{`# web frontend
+apiVersion: v1
+kind: Service
+metadata:
+  name: web # the service name
+  labels:
+    app: web
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+spec:
+  # exposed ports
+  ports:
+    - port: 80
+      targetPort: 8080
+  selector:
+    app: web
+`, `kind: ConfigMap
+apiVersion: v1
+metadata:
+  name: config
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+data:
+  key: "value"
+`}
+
+	docs, err := ExtractDocumentsPreserving([]byte(input), func(yamlDoc interface{}) error {
+		addResourceLabels(yamlDoc, labels)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, docs, len(expected))
+	for i := range docs {
+		assert.Equal(t, expected[i], string(docs[i]))
+	}
+}
+
+func Test_ExtractDocumentsPreserving_NoPostProcess(t *testing.T) {
+	input := `kind: Namespace # keep me
+apiVersion: v1
+metadata:
+  name: "test"
+---
+---
+`
+
+	docs, err := ExtractDocumentsPreserving([]byte(input), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte(`kind: Namespace # keep me
+apiVersion: v1
+metadata:
+  name: "test"
+`)}, docs)
+}
+
+func Test_ExtractDocumentsPreserving_BlockScalars(t *testing.T) {
+	input := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: scripts
+data:
+  entrypoint.sh: |
+    #!/bin/sh
+    set -e
+
+    exec "$@"
+  motd: >-
+    Welcome to the best cluster.
+  banner: >-
+    to be
+    replaced
+`
+	expected := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: scripts
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+data:
+  entrypoint.sh: |
+    #!/bin/sh
+    set -e
+
+    exec "$@"
+  motd: >-
+    Welcome to the best cluster.
+  banner: >-
+    replaced
+
+    value
+`
+
+	labels := GetHelmAppLabels("best-name", "best-owner")
+	docs, err := ExtractDocumentsWithOptions([]byte(input), func(yamlDoc interface{}) error {
+		addResourceLabels(yamlDoc, labels)
+		yamlDoc.(map[string]interface{})["data"].(map[string]interface{})["banner"] = "replaced\nvalue"
+		return nil
+	}, ExtractOptions{PreserveFormatting: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{expected}, toStrings(docs))
+}
+
+func Test_ExtractDocuments_Anchors(t *testing.T) {
+	input := `x-labels: &labels
+  app: web
+x-metadata: &metadata
+  namespace: frontend
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  <<: *metadata
+  name: web
+  labels: *labels
+spec:
+  selector:
+    matchLabels: *labels
+`
+
+	postProcess := func(yamlDoc interface{}) error {
+		addResourceLabels(yamlDoc, GetHelmAppLabels("best-name", "best-owner"))
+		return nil
+	}
+
+	t.Run("aliases are expanded by default", func(t *testing.T) {
+		docs, err := ExtractDocuments([]byte(input), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: web
+  name: web
+  namespace: frontend
+spec:
+  selector:
+    matchLabels:
+      app: web
+x-labels:
+  app: web
+x-metadata:
+  namespace: frontend
+`}, toStrings(docs))
+	})
+
+	t.Run("anchors and aliases are kept when preserving formatting", func(t *testing.T) {
+		docs, err := ExtractDocumentsPreserving([]byte(input), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{input}, toStrings(docs))
+	})
+
+	t.Run("modified aliases are replaced when preserving formatting", func(t *testing.T) {
+		docs, err := ExtractDocumentsPreserving([]byte(input), postProcess)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{`x-labels: &labels
+  app: web
+x-metadata: &metadata
+  namespace: frontend
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  <<: *metadata
+  name: web
+  labels:
+    app: web
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+spec:
+  selector:
+    matchLabels: *labels
+`}, toStrings(docs))
+	})
+}
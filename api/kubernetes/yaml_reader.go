@@ -0,0 +1,134 @@
+package kubernetes
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestReader decodes the documents of a yaml stream one at a time as they are requested,
+// so that a large manifest can be processed without holding all its documents in memory
+type ManifestReader struct {
+	decoder    *yaml.Decoder
+	sizeReader *documentSizeReader
+	opts       ExtractOptions
+	// next is the index of the next document to read from the stream
+	next int
+	// index is the index of the document the last resource returned by Next comes from
+	index int
+	// pending holds the resources of the current document that are yet to be returned
+	pending []*yaml.Node
+	// returned is the number of resources of the current document returned so far
+	returned int
+	err      error
+}
+
+// NewManifestReader returns a ManifestReader reading the documents of r with the default extraction limits
+func NewManifestReader(r io.Reader) *ManifestReader {
+	return NewManifestReaderWithOptions(r, ExtractOptions{})
+}
+
+// NewManifestReaderWithOptions returns a ManifestReader reading the documents of r,
+// enforcing the document count and size limits of opts
+func NewManifestReaderWithOptions(r io.Reader, opts ExtractOptions) *ManifestReader {
+	opts = opts.withDefaults()
+	sizeReader := &documentSizeReader{r: r, limit: opts.MaxDocumentBytes}
+
+	return &ManifestReader{
+		decoder:    yaml.NewDecoder(sizeReader),
+		sizeReader: sizeReader,
+		opts:       opts,
+	}
+}
+
+// Next returns the next resource of the stream, skipping empty documents unless the KeepEmptyDocuments option is set,
+// in which case a nil resource is returned for each of them. Like ExtractDocuments, the elements of a top-level
+// sequence are returned as separate resources. It returns io.EOF once there are no more documents,
+// and keeps returning the same error after a failure.
+func (r *ManifestReader) Next() (map[string]interface{}, error) {
+	for {
+		for len(r.pending) > 0 {
+			node := r.pending[0]
+			r.pending = r.pending[1:]
+
+			var m map[string]interface{}
+			if err := node.Decode(&m); err != nil {
+				r.err = errors.Wrap(newDocumentError(r.index, err), "failed to unmarshal yaml manifest")
+				return nil, r.err
+			}
+
+			// if decoded document is empty
+			if m == nil {
+				continue
+			}
+
+			if r.opts.Strict {
+				if err := validateStrict(m); err != nil {
+					r.err = errors.Wrap(newDocumentError(r.index, err), "strict validation failed")
+					return nil, r.err
+				}
+			}
+
+			r.returned++
+			return m, nil
+		}
+
+		if r.opts.KeepEmptyDocuments && r.returned == 0 && r.next > 0 {
+			r.returned++
+			return nil, nil
+		}
+
+		index, doc, err := r.nextNode()
+		if err != nil {
+			return nil, err
+		}
+
+		r.index = index
+		r.pending = resourceNodes(doc)
+		r.returned = 0
+	}
+}
+
+// Index returns the zero-based index in the stream of the document the last resource returned by Next comes from
+func (r *ManifestReader) Index() int {
+	return r.index
+}
+
+// nextNode decodes the next document of the stream as a yaml node along with its index
+func (r *ManifestReader) nextNode() (int, *yaml.Node, error) {
+	if r.err != nil {
+		return 0, nil, r.err
+	}
+
+	index := r.next
+	r.sizeReader.reset()
+
+	var doc yaml.Node
+	err := r.decoder.Decode(&doc)
+
+	switch {
+	// if there are no more documents in the file
+	case errors.Is(err, io.EOF):
+		r.err = io.EOF
+	// a manifest of exactly MaxDocuments documents is accepted, only a document past the limit fails
+	case r.opts.MaxDocuments > 0 && index >= r.opts.MaxDocuments:
+		r.err = errors.Wrapf(ErrTooManyDocuments, "limit of %d documents reached", r.opts.MaxDocuments)
+	case r.sizeReader.exceeded:
+		r.err = errors.Wrap(newDocumentError(index, ErrDocumentTooLarge), "failed to unmarshal yaml manifest")
+	case err != nil:
+		r.err = errors.Wrap(newDocumentError(index, err), "failed to unmarshal yaml manifest")
+	}
+
+	if r.err == nil && r.opts.MaxDepth > 0 && nodeDepth(&doc, make(map[*yaml.Node]int)) > r.opts.MaxDepth {
+		r.err = errors.Wrapf(newDocumentError(index, ErrMaxDepthExceeded), "limit of %d levels reached", r.opts.MaxDepth)
+	}
+
+	if r.err != nil {
+		return 0, nil, r.err
+	}
+
+	r.next++
+
+	return index, &doc, nil
+}
@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ManifestReader(t *testing.T) {
+	input := `---
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+# only a comment
+---
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: second
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: third
+---
+`
+
+	reader := NewManifestReader(strings.NewReader(input))
+
+	names := make([]string, 0)
+	indexes := make([]int, 0)
+	for {
+		m, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		names = append(names, metadataString(m, "name"))
+		indexes = append(indexes, reader.Index())
+	}
+
+	assert.Equal(t, []string{"first", "second", "third"}, names)
+	assert.Equal(t, []int{1, 3, 3}, indexes)
+
+	_, err := reader.Next()
+	assert.Equal(t, io.EOF, err, "Next should keep returning io.EOF once done")
+}
+
+func Test_ManifestReader_Errors(t *testing.T) {
+	t.Run("invalid document", func(t *testing.T) {
+		reader := NewManifestReader(strings.NewReader("kind: ConfigMap\n---\nkind: [\n"))
+
+		_, err := reader.Next()
+		require.NoError(t, err)
+
+		_, err = reader.Next()
+		var docErr *DocumentError
+		assert.ErrorAs(t, err, &docErr)
+		assert.Equal(t, 1, docErr.Index)
+
+		_, again := reader.Next()
+		assert.Equal(t, err, again)
+	})
+
+	t.Run("too many documents", func(t *testing.T) {
+		reader := NewManifestReaderWithOptions(strings.NewReader("kind: ConfigMap\n---\nkind: Secret\n"), ExtractOptions{MaxDocuments: 1})
+
+		_, err := reader.Next()
+		require.NoError(t, err)
+
+		_, err = reader.Next()
+		assert.ErrorIs(t, err, ErrTooManyDocuments)
+	})
+}
@@ -0,0 +1,378 @@
+package kubernetes
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ResourceRef identifies a resource of a manifest
+type ResourceRef struct {
+	Kind       string
+	Name       string
+	Namespace  string
+	APIVersion string
+}
+
+// ManifestSummary describes the resources of a manifest
+type ManifestSummary struct {
+	// ResourceCount is the number of resources, the items of a list are counted individually and the list itself is not
+	ResourceCount int
+	// Kinds is the number of resources of each kind, keyed by lowercase kind
+	Kinds map[string]int
+	// Namespaces is the sorted list of namespaces, as returned by GetNamespaces
+	Namespaces []string
+	// NamespaceCounts is the number of resources that land in each namespace, resources that don't specify one
+	// being counted in the default namespace and cluster-scoped resources under an empty key
+	NamespaceCounts map[string]int
+	// Resources references every resource, in the order they are defined
+	Resources []ResourceRef
+}
+
+// Summarize describes the resources of a manifest, decoding it only once.
+// It returns an error if the metadata or the namespace of a resource are not of the expected type.
+func Summarize(manifestYaml []byte) (*ManifestSummary, error) {
+	summary := &ManifestSummary{
+		Kinds:           make(map[string]int),
+		Namespaces:      make([]string, 0),
+		NamespaceCounts: make(map[string]int),
+		Resources:       make([]ResourceRef, 0),
+	}
+	namespaces := make(map[string]struct{})
+
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		kind, _ := resource["kind"].(string)
+
+		namespace, err := getResourceNamespace(resource)
+		if err != nil {
+			return err
+		}
+
+		if namesNamespace(kind) {
+			namespaces[namespace] = struct{}{}
+		}
+		summary.NamespaceCounts[effectiveNamespace(resource)]++
+
+		summary.Kinds[normalizeKind(kind)]++
+		summary.Resources = append(summary.Resources, newResourceRef(resource))
+		summary.ResourceCount++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for namespace := range namespaces {
+		summary.Namespaces = append(summary.Namespaces, namespace)
+	}
+	sort.Strings(summary.Namespaces)
+
+	return summary, nil
+}
+
+// ListResources returns a reference to every resource of a manifest, in the order they are defined.
+// Lists are flattened into their items and empty documents are skipped.
+func ListResources(manifestYaml []byte) ([]ResourceRef, error) {
+	summary, err := Summarize(manifestYaml)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary.Resources, nil
+}
+
+func newResourceRef(resource map[string]interface{}) ResourceRef {
+	kind, _ := resource["kind"].(string)
+	apiVersion, _ := resource["apiVersion"].(string)
+
+	return ResourceRef{
+		Kind:       kind,
+		Name:       metadataString(resource, "name"),
+		Namespace:  metadataString(resource, "namespace"),
+		APIVersion: apiVersion,
+	}
+}
+
+// ErrResourceNotFound is returned when a manifest doesn't define the requested resource
+var ErrResourceNotFound = errors.New("resource not found in manifest")
+
+// GetResource returns the first resource of a manifest with the given kind (case-insensitive) and name,
+// encoded as a standalone yaml document. Items of a list are matched individually.
+// It returns ErrResourceNotFound if there is no such resource.
+func GetResource(manifestYaml []byte, kind, name string) ([]byte, error) {
+	var out []byte
+
+	err := forEachDocument(manifestYaml, func(index int, doc map[string]interface{}) error {
+		return visitResources(doc, func(resource map[string]interface{}) error {
+			if !matchesKind(resource, []string{kind}) || metadataString(resource, "name") != name {
+				return nil
+			}
+
+			var err error
+			out, err = encodeDocument(resource)
+			if err != nil {
+				return errors.Wrap(newDocumentError(index, err), "failed to marshal yaml manifest")
+			}
+
+			return errStopVisit
+		})
+	})
+	if errors.Is(err, errStopVisit) {
+		return out, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, errors.Wrapf(ErrResourceNotFound, "%s '%s'", kind, name)
+}
+
+// ErrFieldNotFound is returned when a field path doesn't resolve in the requested resource
+var ErrFieldNotFound = errors.New("field not found in resource")
+
+// GetField returns the value at jsonPath in the first resource of a manifest with the given kind (case-insensitive)
+// and name, such as an int for "spec.replicas" or a []interface{} for "spec.template.spec.containers".
+// The path is made of map keys separated by dots, a segment made of digits indexes a list instead, e.g.
+// "spec.template.spec.containers.0.image". Keys holding dots can't be reached and an empty path returns the
+// whole resource. It returns ErrResourceNotFound if there is no such resource and ErrFieldNotFound if the path
+// doesn't resolve, naming the first segment that doesn't.
+func GetField(manifestYaml []byte, kind, name, jsonPath string) (interface{}, error) {
+	var resource map[string]interface{}
+
+	err := WalkResources(manifestYaml, func(r map[string]interface{}) error {
+		if !isResourceNamed(r, kind, name, "") {
+			return nil
+		}
+
+		resource = r
+		return errStopVisit
+	})
+	if err != nil && !errors.Is(err, errStopVisit) {
+		return nil, err
+	}
+	if resource == nil {
+		return nil, errors.Wrapf(ErrResourceNotFound, "%s '%s'", kind, name)
+	}
+
+	if jsonPath == "" {
+		return resource, nil
+	}
+
+	var value interface{} = resource
+	segments := strings.Split(jsonPath, ".")
+	for i, segment := range segments {
+		at := strings.Join(segments[:i+1], ".")
+		if segment == "" {
+			return nil, errors.Errorf("invalid field path '%s': empty segment at '%s'", jsonPath, at)
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return nil, errors.Wrapf(ErrFieldNotFound, "'%s': no key '%s'", at, segment)
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 {
+				return nil, errors.Wrapf(ErrFieldNotFound, "'%s': '%s' is not a list index", at, segment)
+			}
+			if index >= len(v) {
+				return nil, errors.Wrapf(ErrFieldNotFound, "'%s': index %d out of range, the list has %d items", at, index, len(v))
+			}
+			value = v[index]
+		default:
+			return nil, errors.Wrapf(ErrFieldNotFound, "'%s': '%s' is neither a map nor a list", at, strings.Join(segments[:i], "."))
+		}
+	}
+
+	return value, nil
+}
+
+// errStopVisit stops visiting the resources of a manifest once the result is known
+var errStopVisit = errors.New("stop visiting resources")
+
+// ContainsResource returns true if a manifest defines a resource of the given kind (case-insensitive) and name.
+// An empty namespace matches any namespace, otherwise resources that don't specify one are considered
+// to be in the default namespace. It stops decoding the manifest as soon as a match is found.
+func ContainsResource(manifestYaml []byte, kind, name, namespace string) (bool, error) {
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		if !matchesKind(resource, []string{kind}) || metadataString(resource, "name") != name {
+			return nil
+		}
+
+		if namespace != "" && effectiveNamespace(resource) != namespace {
+			return nil
+		}
+
+		return errStopVisit
+	})
+	if errors.Is(err, errStopVisit) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// ExtractResourcesByKind returns the resources of a manifest whose kind matches one of kinds (case-insensitively),
+// each of them encoded as a standalone yaml document. Items of a list are matched individually.
+func ExtractResourcesByKind(manifestYaml []byte, kinds ...string) ([][]byte, error) {
+	docs := make([][]byte, 0)
+
+	err := forEachDocument(manifestYaml, func(index int, doc map[string]interface{}) error {
+		return visitResources(doc, func(resource map[string]interface{}) error {
+			if !matchesKind(resource, kinds) {
+				return nil
+			}
+
+			out, err := encodeDocument(resource)
+			if err != nil {
+				return errors.Wrap(newDocumentError(index, err), "failed to marshal yaml manifest")
+			}
+
+			docs = append(docs, out)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// ExtractApiVersions returns the sorted list of apiVersions used by the resources of a manifest.
+// The items of a list are checked individually and resources without an apiVersion are ignored.
+func ExtractApiVersions(manifestYaml []byte) ([]string, error) {
+	found := make(map[string]struct{})
+
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		if apiVersion, _ := resource["apiVersion"].(string); apiVersion != "" {
+			found[apiVersion] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	apiVersions := make([]string, 0, len(found))
+	for apiVersion := range found {
+		apiVersions = append(apiVersions, apiVersion)
+	}
+	sort.Strings(apiVersions)
+
+	return apiVersions, nil
+}
+
+// CountResources returns the number of resources of each kind found in a manifest, keyed by lowercase kind.
+// Items of a list are counted individually and the list itself is not counted.
+func CountResources(manifestYaml []byte) (map[string]int, error) {
+	summary, err := Summarize(manifestYaml)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary.Kinds, nil
+}
+
+// CountResourcesByNamespace returns the number of resources of a manifest that land in each namespace.
+// Resources that don't specify a namespace are counted in the default namespace, and cluster-scoped resources
+// under an empty key. Items of a list are counted individually and the list itself is not counted.
+func CountResourcesByNamespace(manifestYaml []byte) (map[string]int, error) {
+	summary, err := Summarize(manifestYaml)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary.NamespaceCounts, nil
+}
+
+// ResourceSizes returns the size in bytes of every resource of a manifest once encoded like ExtractDocuments does,
+// keyed by lowercase kind and name as kind/name, so that resources approaching the api server object size limit
+// can be reported. Items of a list are measured individually. When resources of different namespaces share
+// the same kind and name, the size of the largest one is reported.
+func ResourceSizes(manifestYaml []byte) (map[string]int, error) {
+	sizes := make(map[string]int)
+
+	err := forEachDocument(manifestYaml, func(index int, doc map[string]interface{}) error {
+		return visitResources(doc, func(resource map[string]interface{}) error {
+			out, err := encodeDocument(resource)
+			if err != nil {
+				return errors.Wrap(newDocumentError(index, err), "failed to marshal yaml manifest")
+			}
+
+			kind, _ := resource["kind"].(string)
+			key := normalizeKind(kind) + "/" + resourceName(resource)
+			sizes[key] = max(sizes[key], len(out))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sizes, nil
+}
+
+// FindOwnedResources returns every resource of a manifest carrying owner references, in the order they are defined.
+// Such resources are managed by a controller, like the ReplicaSets of a Deployment, and shouldn't be applied directly.
+// Items of a list are checked individually.
+func FindOwnedResources(manifestYaml []byte) ([]ResourceRef, error) {
+	refs := make([]ResourceRef, 0)
+
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		metadata, _ := resource["metadata"].(map[string]interface{})
+		if owners, _ := metadata["ownerReferences"].([]interface{}); len(owners) > 0 {
+			refs = append(refs, newResourceRef(resource))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return refs, nil
+}
+
+func normalizeKind(kind string) string {
+	return strings.ToLower(strings.TrimSpace(kind))
+}
+
+// matchesKind returns true if the kind of the resource is one of kinds, ignoring case
+func matchesKind(resource map[string]interface{}, kinds []string) bool {
+	kind, _ := resource["kind"].(string)
+	for _, k := range kinds {
+		if strings.EqualFold(kind, k) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// metadataString returns the string value of "Resource"->metadata->field, or an empty string
+func metadataString(resource map[string]interface{}, field string) string {
+	metadata, _ := resource["metadata"].(map[string]interface{})
+	value, _ := metadata[field].(string)
+	return value
+}
+
+// resourceName returns "Resource"->metadata->name, falling back to metadata.generateName
+// for resources whose name is generated by the api server
+func resourceName(resource map[string]interface{}) string {
+	if name := metadataString(resource, "name"); name != "" {
+		return name
+	}
+
+	return metadataString(resource, "generateName")
+}
+
+// resourceKey identifies a resource as kind/namespace/name, using its lowercase kind and effective namespace
+func resourceKey(resource map[string]interface{}) string {
+	kind, _ := resource["kind"].(string)
+	return normalizeKind(kind) + "/" + effectiveNamespace(resource) + "/" + metadataString(resource, "name")
+}
@@ -0,0 +1,491 @@
+package kubernetes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExtractResourcesByKind(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: StatefulSet
+    metadata:
+      name: db
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: db
+kind: List
+`
+
+	docs, err := ExtractResourcesByKind([]byte(input), "deployment", "StatefulSet")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`, `apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+`}, toStrings(docs))
+
+	docs, err = ExtractResourcesByKind([]byte(input), "ConfigMap")
+	assert.NoError(t, err)
+	assert.Empty(t, docs)
+}
+
+func toStrings(docs [][]byte) []string {
+	result := make([]string, len(docs))
+	for i, doc := range docs {
+		result[i] = string(doc)
+	}
+
+	return result
+}
+
+func Test_CountResources(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: deployment
+    metadata:
+      name: db
+  - apiVersion: example.com/v1
+    kind: Widget
+    metadata:
+      name: db
+kind: List
+`
+
+	counts, err := CountResources([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"deployment": 2,
+		"service":    1,
+		"widget":     1,
+	}, counts)
+}
+
+func Test_ListResources(t *testing.T) {
+	input := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: frontend
+---
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+      namespace: frontend
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+      namespace: frontend
+kind: List
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+	resources, err := ListResources([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []ResourceRef{
+		{Kind: "Namespace", Name: "frontend", APIVersion: "v1"},
+		{Kind: "Deployment", Name: "web", Namespace: "frontend", APIVersion: "apps/v1"},
+		{Kind: "Service", Name: "web", Namespace: "frontend", APIVersion: "v1"},
+		{Kind: "ConfigMap", Name: "config", APIVersion: "v1"},
+	}, resources)
+}
+
+func Test_ContainsResource(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: api
+      namespace: backend
+kind: List
+`
+
+	tests := []struct {
+		name      string
+		kind      string
+		resource  string
+		namespace string
+		want      bool
+	}{
+		{name: "any namespace", kind: "service", resource: "web", want: true},
+		{name: "default namespace", kind: "Service", resource: "web", namespace: "default", want: true},
+		{name: "other namespace", kind: "Service", resource: "web", namespace: "backend"},
+		{name: "list item", kind: "Deployment", resource: "api", namespace: "backend", want: true},
+		{name: "different kind", kind: "Deployment", resource: "web"},
+		{name: "different name", kind: "Service", resource: "api"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := ContainsResource([]byte(input), tt.kind, tt.resource, tt.namespace)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, found)
+		})
+	}
+}
+
+func Test_ContainsResource_StopsAtFirstMatch(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+kind: [invalid
+`
+
+	found, err := ContainsResource([]byte(input), "Service", "web", "")
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	_, err = ContainsResource([]byte(input), "Service", "api", "")
+	assert.Error(t, err)
+}
+
+func Test_GetResource(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+    spec:
+      replicas: 2
+kind: List
+`
+
+	result, err := GetResource([]byte(input), "deployment", "web")
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+`, string(result))
+
+	result, err = GetResource([]byte(input), "Service", "web")
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`, string(result))
+
+	_, err = GetResource([]byte(input), "ConfigMap", "web")
+	assert.ErrorIs(t, err, ErrResourceNotFound)
+}
+
+func Test_GetField(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+      labels:
+        app: web
+    spec:
+      replicas: 2
+      template:
+        spec:
+          containers:
+            - name: nginx
+              image: nginx:1.25
+            - name: sidecar
+              image: busybox
+kind: List
+`
+
+	tests := []struct {
+		name     string
+		path     string
+		expected interface{}
+	}{
+		{name: "scalar", path: "spec.replicas", expected: 2},
+		{name: "map", path: "metadata.labels", expected: map[string]interface{}{"app": "web"}},
+		{name: "list index", path: "spec.template.spec.containers.1.image", expected: "busybox"},
+		{name: "list", path: "spec.template.spec.containers.0", expected: map[string]interface{}{"name": "nginx", "image": "nginx:1.25"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := GetField([]byte(input), "deployment", "web", tt.path)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+
+	value, err := GetField([]byte(input), "Service", "web", "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "web"}}, value)
+}
+
+func Test_GetField_Errors(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: nginx
+`
+
+	tests := []struct {
+		name          string
+		path          string
+		expectedError string
+	}{
+		{name: "missing key", path: "spec.strategy.type", expectedError: "'spec.strategy': no key 'strategy'"},
+		{name: "index out of range", path: "spec.template.spec.containers.1", expectedError: "'spec.template.spec.containers.1': index 1 out of range, the list has 1 items"},
+		{name: "key on a list", path: "spec.template.spec.containers.name", expectedError: "'spec.template.spec.containers.name': 'name' is not a list index"},
+		{name: "traversing a scalar", path: "spec.replicas.value", expectedError: "'spec.replicas.value': 'spec.replicas' is neither a map nor a list"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := GetField([]byte(input), "Deployment", "web", tt.path)
+			assert.ErrorIs(t, err, ErrFieldNotFound)
+			assert.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+
+	_, err := GetField([]byte(input), "Deployment", "web", "spec..replicas")
+	assert.ErrorContains(t, err, "invalid field path 'spec..replicas': empty segment at 'spec.'")
+
+	_, err = GetField([]byte(input), "Deployment", "api", "spec.replicas")
+	assert.ErrorIs(t, err, ErrResourceNotFound)
+}
+
+func Test_Summarize(t *testing.T) {
+	input := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: frontend
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: frontend
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+      namespace: frontend
+  - apiVersion: v1
+    kind: service
+    metadata:
+      name: api
+kind: List
+`
+
+	summary, err := Summarize([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, &ManifestSummary{
+		ResourceCount:   4,
+		Kinds:           map[string]int{"namespace": 1, "service": 2, "deployment": 1},
+		Namespaces:      []string{"", "frontend"},
+		NamespaceCounts: map[string]int{"": 1, "frontend": 2, "default": 1},
+		Resources: []ResourceRef{
+			{Kind: "Namespace", Name: "frontend", APIVersion: "v1"},
+			{Kind: "Service", Name: "web", Namespace: "frontend", APIVersion: "v1"},
+			{Kind: "Deployment", Name: "web", Namespace: "frontend", APIVersion: "apps/v1"},
+			{Kind: "service", Name: "api", APIVersion: "v1"},
+		},
+	}, summary)
+}
+
+func Test_CountResourcesByNamespace(t *testing.T) {
+	input := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: shop
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+      namespace: shop
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+      namespace: shop
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+kind: List
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+  namespace: default
+`
+
+	counts, err := CountResourcesByNamespace([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"": 2, "shop": 2, "default": 2}, counts)
+}
+
+func Test_ExtractApiVersions(t *testing.T) {
+	input := `apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: web
+---
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+  - kind: ConfigMap
+    metadata:
+      name: config
+kind: List
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+`
+
+	apiVersions, err := ExtractApiVersions([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"apps/v1", "extensions/v1beta1", "v1"}, apiVersions)
+}
+
+func Test_ResourceSizes(t *testing.T) {
+	config := "apiVersion: v1\ndata:\n  key: " + strings.Repeat("a", 1000) + "\nkind: ConfigMap\nmetadata:\n  name: config\n"
+	service := "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  namespace: shop\n"
+	smallerService := "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n"
+
+	input := config + "---\n---\napiVersion: v1\nkind: List\nitems:\n  - apiVersion: v1\n    kind: Service\n    metadata:\n      name: web\n      namespace: shop\n---\n" + smallerService
+
+	sizes, err := ResourceSizes([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"configmap/config": len(config),
+		"service/web":      len(service),
+	}, sizes)
+}
+
+func Test_FindOwnedResources(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  name: web-5d78c9869d
+  namespace: shop
+  ownerReferences:
+    - apiVersion: apps/v1
+      controller: true
+      kind: Deployment
+      name: web
+      uid: 0b3f5c2e-1f4a-4c59-9a3e-7d1e8f6a2b11
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Pod
+    metadata:
+      name: web-5d78c9869d-x2k9p
+      ownerReferences:
+        - apiVersion: apps/v1
+          kind: ReplicaSet
+          name: web-5d78c9869d
+          uid: 6c2a8e4d-2b7f-4e11-8d93-1a5b7c9e3f20
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+      ownerReferences: []
+kind: List
+`
+
+	refs, err := FindOwnedResources([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []ResourceRef{
+		{Kind: "ReplicaSet", Name: "web-5d78c9869d", Namespace: "shop", APIVersion: "apps/v1"},
+		{Kind: "Pod", Name: "web-5d78c9869d-x2k9p", APIVersion: "v1"},
+	}, refs)
+}
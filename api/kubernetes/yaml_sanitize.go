@@ -0,0 +1,21 @@
+package kubernetes
+
+// serverManagedMetadataFields are the metadata fields populated by the api server that conflict with an apply
+var serverManagedMetadataFields = []string{"uid", "resourceVersion", "creationTimestamp", "managedFields"}
+
+// SanitizeForApply removes the status and the server-managed metadata fields (uid, resourceVersion,
+// creationTimestamp and managedFields) from every resource of a manifest, such as the ones exported
+// with kubectl get -o yaml, so that it can be applied again. Everything else is left intact.
+func SanitizeForApply(manifestYaml []byte) ([]byte, error) {
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		delete(resource, "status")
+
+		if metadata, ok := resource["metadata"].(map[string]interface{}); ok {
+			for _, field := range serverManagedMetadataFields {
+				delete(metadata, field)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,71 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SanitizeForApply(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  creationTimestamp: "2024-01-02T10:00:00Z"
+  generation: 1
+  labels:
+    app: web
+  managedFields:
+    - apiVersion: apps/v1
+      manager: kubectl
+  name: web
+  namespace: default
+  resourceVersion: "1234"
+  uid: 6f1c2b4e-3a7d-4f5e-9b1a-2c3d4e5f6a7b
+spec:
+  replicas: 2
+status:
+  availableReplicas: 2
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+      uid: 0b1c2d3e-4f5a-6b7c-8d9e-0f1a2b3c4d5e
+    spec:
+      status: not-a-status-block
+    status:
+      loadBalancer: {}
+kind: List
+metadata:
+  resourceVersion: ""
+`
+	expected := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  generation: 1
+  labels:
+    app: web
+  name: web
+  namespace: default
+spec:
+  replicas: 2
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+    spec:
+      status: not-a-status-block
+kind: List
+metadata:
+  resourceVersion: ""
+`
+
+	result, err := SanitizeForApply([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
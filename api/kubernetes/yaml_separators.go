@@ -0,0 +1,57 @@
+package kubernetes
+
+import "bytes"
+
+// DocumentStyle describes how the documents of a manifest are delimited
+type DocumentStyle struct {
+	// LeadingSeparator is set when the first document is preceded by a "---" marker
+	LeadingSeparator bool
+	// EndMarkers is set when the documents are terminated by "..." markers
+	EndMarkers bool
+}
+
+// DetectDocumentStyle returns the style of the document markers used by a manifest.
+// Comments, blank lines and directives before the first marker are ignored when looking for a leading separator.
+func DetectDocumentStyle(manifestYaml []byte) DocumentStyle {
+	style := DocumentStyle{}
+	beforeContent := true
+
+	for _, line := range bytes.SplitAfter(manifestYaml, []byte("\n")) {
+		trimmed := bytes.TrimSpace(line)
+
+		if beforeContent {
+			if len(trimmed) == 0 || trimmed[0] == '#' || trimmed[0] == '%' {
+				continue
+			}
+
+			style.LeadingSeparator = isDocumentSeparator(line)
+			beforeContent = false
+		}
+
+		if bytes.Equal(bytes.TrimRight(line, " \t\r\n"), []byte("...")) {
+			style.EndMarkers = true
+		}
+	}
+
+	return style
+}
+
+// JoinDocuments joins yaml documents into a single multi-document yaml using the given style,
+// documents are always separated by "---\n"
+func JoinDocuments(docs [][]byte, style DocumentStyle) []byte {
+	var out bytes.Buffer
+
+	for i, doc := range docs {
+		if i > 0 || style.LeadingSeparator {
+			out.WriteString("---\n")
+		}
+
+		out.Write(doc)
+
+		if style.EndMarkers {
+			out.WriteString("...\n")
+		}
+	}
+
+	return out.Bytes()
+}
@@ -0,0 +1,92 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DetectDocumentStyle(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected DocumentStyle
+	}{
+		{
+			name:  "no markers",
+			input: "kind: Service\n---\nkind: ConfigMap\n",
+		},
+		{
+			name:     "leading separator after comments",
+			input:    "# Source: chart/templates/service.yaml\n\n---\nkind: Service\n",
+			expected: DocumentStyle{LeadingSeparator: true},
+		},
+		{
+			name:     "leading separator with a comment",
+			input:    "--- # service\nkind: Service\n",
+			expected: DocumentStyle{LeadingSeparator: true},
+		},
+		{
+			name:     "directive and end markers",
+			input:    "%YAML 1.2\n---\nkind: Service\n...\n---\nkind: ConfigMap\n...\n",
+			expected: DocumentStyle{LeadingSeparator: true, EndMarkers: true},
+		},
+		{
+			name:  "separator inside a document",
+			input: "kind: ConfigMap\ndata:\n  file: |\n    ---\n    ...\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, DetectDocumentStyle([]byte(tt.input)))
+		})
+	}
+}
+
+func Test_JoinDocuments(t *testing.T) {
+	docs := [][]byte{[]byte("kind: Service\n"), []byte("kind: ConfigMap\n")}
+
+	assert.Equal(t, "kind: Service\n---\nkind: ConfigMap\n", string(JoinDocuments(docs, DocumentStyle{})))
+	assert.Equal(t, "---\nkind: Service\n---\nkind: ConfigMap\n", string(JoinDocuments(docs, DocumentStyle{LeadingSeparator: true})))
+	assert.Equal(t, "kind: Service\n...\n---\nkind: ConfigMap\n...\n", string(JoinDocuments(docs, DocumentStyle{EndMarkers: true})))
+}
+
+func Test_AddAppLabels_KeepsDocumentMarkers(t *testing.T) {
+	input := `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+...
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+...
+`
+	expected := `---
+apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: web
+...
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: config
+...
+`
+
+	result, err := AddAppLabels([]byte(input), GetHelmAppLabels("best-name", "best-owner"))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
@@ -0,0 +1,71 @@
+package kubernetes
+
+import "fmt"
+
+// ServicePort is a port exposed by a Service of a manifest
+type ServicePort struct {
+	// ServiceName is the name of the Service exposing the port
+	ServiceName string
+	// ServiceType is the type of the Service, ClusterIP when not specified
+	ServiceType string
+	Name        string
+	Port        int
+	// TargetPort is either a port number or the name of a container port, empty when not specified
+	TargetPort string
+	// Protocol defaults to TCP
+	Protocol string
+	// NodePort is 0 unless specified
+	NodePort int
+}
+
+// ExtractServicePorts returns the ports exposed by the Services of a manifest, in the order they are defined
+func ExtractServicePorts(manifestYaml []byte) ([]ServicePort, error) {
+	ports := make([]ServicePort, 0)
+
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		if !matchesKind(resource, []string{"service"}) {
+			return nil
+		}
+
+		spec, _ := resource["spec"].(map[string]interface{})
+
+		serviceType, _ := spec["type"].(string)
+		if serviceType == "" {
+			serviceType = "ClusterIP"
+		}
+
+		entries, _ := spec["ports"].([]interface{})
+		for _, e := range entries {
+			entry, ok := e.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			port := ServicePort{
+				ServiceName: metadataString(resource, "name"),
+				ServiceType: serviceType,
+				Protocol:    "TCP",
+			}
+			port.Name, _ = entry["name"].(string)
+			port.Port, _ = entry["port"].(int)
+			port.NodePort, _ = entry["nodePort"].(int)
+
+			if protocol, _ := entry["protocol"].(string); protocol != "" {
+				port.Protocol = protocol
+			}
+
+			if targetPort, ok := entry["targetPort"]; ok && targetPort != nil {
+				port.TargetPort = fmt.Sprintf("%v", targetPort)
+			}
+
+			ports = append(ports, port)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ports, nil
+}
@@ -0,0 +1,58 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ExtractServicePorts(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+    - name: http
+      port: 80
+      targetPort: 8080
+    - name: metrics
+      port: 9090
+      targetPort: metrics
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: dns
+    spec:
+      ports:
+        - nodePort: 30053
+          port: 53
+          protocol: UDP
+      type: NodePort
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: ingress
+    spec:
+      ports:
+        - port: 443
+      type: LoadBalancer
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+kind: List
+`
+
+	ports, err := ExtractServicePorts([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []ServicePort{
+		{ServiceName: "web", ServiceType: "ClusterIP", Name: "http", Port: 80, TargetPort: "8080", Protocol: "TCP"},
+		{ServiceName: "web", ServiceType: "ClusterIP", Name: "metrics", Port: 9090, TargetPort: "metrics", Protocol: "TCP"},
+		{ServiceName: "dns", ServiceType: "NodePort", Port: 53, Protocol: "UDP", NodePort: 30053},
+		{ServiceName: "ingress", ServiceType: "LoadBalancer", Port: 443, Protocol: "TCP"},
+	}, ports)
+}
@@ -0,0 +1,65 @@
+package kubernetes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var fileNameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9\.\-]+`)
+
+// SplitToFiles returns every resource of a manifest encoded as a standalone yaml document, keyed by a filesystem-safe
+// "<namespace>_<kind>_<name>.yaml" file name. Cluster-scoped resources have no namespace part and resources that
+// don't specify a namespace are in the default namespace. Lists are flattened into their items and empty documents
+// are skipped. When several resources end up with the same file name, an index is appended to the following ones,
+// e.g. "default_configmap_config-2.yaml".
+func SplitToFiles(manifestYaml []byte) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	err := forEachDocument(manifestYaml, func(index int, doc map[string]interface{}) error {
+		return visitResources(doc, func(resource map[string]interface{}) error {
+			out, err := encodeDocument(resource)
+			if err != nil {
+				return errors.Wrap(newDocumentError(index, err), "failed to marshal yaml manifest")
+			}
+
+			base := resourceFileName(resource)
+			name := base + ".yaml"
+			for i := 2; files[name] != nil; i++ {
+				name = fmt.Sprintf("%s-%d.yaml", base, i)
+			}
+
+			files[name] = out
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// resourceFileName returns the file name of a resource without its extension
+func resourceFileName(resource map[string]interface{}) string {
+	kind, _ := resource["kind"].(string)
+
+	parts := make([]string, 0, 3)
+	if namespace := effectiveNamespace(resource); namespace != "" {
+		parts = append(parts, namespace)
+	}
+	parts = append(parts, normalizeKind(kind), resourceName(resource))
+
+	for i, part := range parts {
+		if part = strings.Trim(fileNameInvalidChars.ReplaceAllString(part, "-"), ".-"); part != "" {
+			parts[i] = part
+			continue
+		}
+
+		parts[i] = "unnamed"
+	}
+
+	return strings.Join(parts, "_")
+}
@@ -0,0 +1,55 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SplitToFiles(t *testing.T) {
+	input := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: shop
+---
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+kind: List
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  generateName: migrate-
+  namespace: shop
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: ../../etc/passwd
+  namespace: shop
+---
+apiVersion: v1
+kind: Secret
+`
+
+	files, err := SplitToFiles([]byte(input))
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string][]byte{
+		"namespace_shop.yaml":             []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: shop\n"),
+		"default_configmap_config.yaml":   []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n"),
+		"default_configmap_config-2.yaml": []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n"),
+		"shop_job_migrate.yaml":           []byte("apiVersion: batch/v1\nkind: Job\nmetadata:\n  generateName: migrate-\n  namespace: shop\n"),
+		"shop_widget_etc-passwd.yaml":     []byte("apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: ../../etc/passwd\n  namespace: shop\n"),
+		"default_secret_unnamed.yaml":     []byte("apiVersion: v1\nkind: Secret\n"),
+	}, files)
+}
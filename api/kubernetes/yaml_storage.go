@@ -0,0 +1,147 @@
+package kubernetes
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// RewriteStorageClass sets the spec.storageClassName of the PersistentVolumeClaims of a manifest, including
+// the volumeClaimTemplates of StatefulSets, to to. Only the claims using the from storage class are rewritten,
+// unless from is empty in which case every claim is.
+func RewriteStorageClass(manifestYaml []byte, from, to string) ([]byte, error) {
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		kind, _ := resource["kind"].(string)
+
+		switch strings.ToLower(kind) {
+		case "persistentvolumeclaim":
+			rewriteClaimStorageClass(resource, from, to)
+		case "statefulset":
+			spec, _ := resource["spec"].(map[string]interface{})
+			templates, _ := spec["volumeClaimTemplates"].([]interface{})
+			for _, t := range templates {
+				if template, ok := t.(map[string]interface{}); ok {
+					rewriteClaimStorageClass(template, from, to)
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func rewriteClaimStorageClass(claim map[string]interface{}, from, to string) {
+	spec, ok := claim["spec"].(map[string]interface{})
+	if !ok {
+		if from != "" {
+			return
+		}
+
+		spec = make(map[string]interface{})
+		claim["spec"] = spec
+	}
+
+	if current, _ := spec["storageClassName"].(string); from == "" || current == from {
+		spec["storageClassName"] = to
+	}
+}
+
+// ExtractStorageRequests returns the spec.resources.requests.storage value of the PersistentVolumeClaims of a manifest
+// keyed by claim name, along with the volumeClaimTemplates of StatefulSets keyed by "<statefulset>/<template>" which
+// can't collide with a claim name. The claims that don't request storage are skipped and the last definition of a name wins.
+func ExtractStorageRequests(manifestYaml []byte) (map[string]string, error) {
+	requests := make(map[string]string)
+
+	err := visitStorageClaims(manifestYaml, func(name string, storage string, replicas int) error {
+		requests[name] = storage
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// TotalStorageRequests returns the total storage requested by the PersistentVolumeClaims of a manifest, together with
+// the claims the StatefulSets create from their volumeClaimTemplates, one per replica (spec.replicas defaults to 1).
+// It fails when a storage request is not a valid quantity or when a StatefulSet has a negative replicas count.
+// Quantities are counted in whole bytes, rounded up.
+func TotalStorageRequests(manifestYaml []byte) (resource.Quantity, error) {
+	var total resource.Quantity
+
+	err := visitStorageClaims(manifestYaml, func(name string, storage string, replicas int) error {
+		quantity, err := resource.ParseQuantity(storage)
+		if err != nil {
+			return errors.Wrapf(err, "invalid storage request %q of claim %s", storage, name)
+		}
+
+		if replicas < 0 {
+			return errors.Errorf("invalid replicas count %d for claim %s", replicas, name)
+		}
+
+		if replicas > 0 && quantity.Value() > math.MaxInt64/int64(replicas) {
+			return errors.Errorf("storage request %q of claim %s overflows for %d replicas", storage, name, replicas)
+		}
+
+		total.Add(*resource.NewQuantity(quantity.Value()*int64(replicas), quantity.Format))
+
+		return nil
+	})
+	if err != nil {
+		return resource.Quantity{}, err
+	}
+
+	return total, nil
+}
+
+// visitStorageClaims calls visit with the name and storage request of every PersistentVolumeClaim and StatefulSet
+// volumeClaimTemplate of a manifest, along with the number of claims it results in. Templates are named after
+// their StatefulSet as "<statefulset>/<template>".
+func visitStorageClaims(manifestYaml []byte, visit func(name string, storage string, replicas int) error) error {
+	return WalkResources(manifestYaml, func(obj map[string]interface{}) error {
+		kind, _ := obj["kind"].(string)
+
+		switch strings.ToLower(kind) {
+		case "persistentvolumeclaim":
+			if storage, ok := claimStorageRequest(obj); ok {
+				return visit(metadataString(obj, "name"), storage, 1)
+			}
+		case "statefulset":
+			spec, _ := obj["spec"].(map[string]interface{})
+
+			replicas := 1
+			if r, ok := spec["replicas"].(int); ok {
+				replicas = r
+			}
+
+			templates, _ := spec["volumeClaimTemplates"].([]interface{})
+			for _, t := range templates {
+				template, ok := t.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				if storage, ok := claimStorageRequest(template); ok {
+					if err := visit(metadataString(obj, "name")+"/"+metadataString(template, "name"), storage, replicas); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func claimStorageRequest(claim map[string]interface{}) (string, bool) {
+	requests, ok := nestedMap(claim, "spec", "resources", "requests")
+	if !ok || requests["storage"] == nil {
+		return "", false
+	}
+
+	return fmt.Sprintf("%v", requests["storage"]), true
+}
@@ -0,0 +1,287 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RewriteStorageClass(t *testing.T) {
+	input := `apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+spec:
+  storageClassName: standard
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: cache
+spec:
+  storageClassName: fast
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  volumeClaimTemplates:
+    - metadata:
+        name: data
+      spec:
+        storageClassName: standard
+    - metadata:
+        name: logs
+      spec:
+        storageClassName: fast
+`
+
+	tests := []struct {
+		name     string
+		from     string
+		expected string
+	}{
+		{
+			name: "matching claims only",
+			from: "standard",
+			expected: `apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+spec:
+  storageClassName: gp3
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: cache
+spec:
+  storageClassName: fast
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  volumeClaimTemplates:
+    - metadata:
+        name: data
+      spec:
+        storageClassName: gp3
+    - metadata:
+        name: logs
+      spec:
+        storageClassName: fast
+`,
+		},
+		{
+			name: "every claim",
+			expected: `apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+spec:
+  storageClassName: gp3
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: cache
+spec:
+  storageClassName: gp3
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  volumeClaimTemplates:
+    - metadata:
+        name: data
+      spec:
+        storageClassName: gp3
+    - metadata:
+        name: logs
+      spec:
+        storageClassName: gp3
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := RewriteStorageClass([]byte(input), tt.from, "gp3")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(result))
+		})
+	}
+}
+
+func Test_ExtractStorageRequests(t *testing.T) {
+	input := `apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+spec:
+  resources:
+    requests:
+      storage: 10Gi
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: PersistentVolumeClaim
+    metadata:
+      name: cache
+    spec:
+      resources:
+        requests:
+          storage: 512Mi
+  - apiVersion: v1
+    kind: PersistentVolumeClaim
+    metadata:
+      name: no-request
+    spec:
+      accessModes: [ReadWriteOnce]
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  replicas: 3
+  volumeClaimTemplates:
+    - metadata:
+        name: pgdata
+      spec:
+        resources:
+          requests:
+            storage: 1Gi
+    - metadata:
+        name: wal
+      spec:
+        resources:
+          requests:
+            storage: 1073741824
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+	requests, err := ExtractStorageRequests([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"data":      "10Gi",
+		"cache":     "512Mi",
+		"db/pgdata": "1Gi",
+		"db/wal":    "1073741824",
+	}, requests)
+
+	// 10Gi + 512Mi + 3 replicas * (1Gi + 1Gi)
+	total, err := TotalStorageRequests([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(16*1024*1024*1024+512*1024*1024), total.Value())
+}
+
+func Test_TotalStorageRequests_InvalidQuantity(t *testing.T) {
+	input := `apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+spec:
+  resources:
+    requests:
+      storage: lots
+`
+
+	_, err := TotalStorageRequests([]byte(input))
+	assert.ErrorContains(t, err, `invalid storage request "lots" of claim data`)
+
+	requests, err := ExtractStorageRequests([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"data": "lots"}, requests)
+}
+
+func Test_TotalStorageRequests_Replicas(t *testing.T) {
+	statefulSet := func(replicas string) []byte {
+		return []byte(`apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  replicas: ` + replicas + `
+  volumeClaimTemplates:
+    - metadata:
+        name: pgdata
+      spec:
+        resources:
+          requests:
+            storage: 1Gi
+`)
+	}
+
+	// a huge number of replicas is multiplied once instead of being summed replica by replica
+	total, err := TotalStorageRequests(statefulSet("2000000000"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2000000000)*1024*1024*1024, total.Value())
+
+	total, err = TotalStorageRequests(statefulSet("0"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), total.Value())
+
+	_, err = TotalStorageRequests(statefulSet("-1"))
+	assert.ErrorContains(t, err, "invalid replicas count -1 for claim db/pgdata")
+
+	_, err = TotalStorageRequests(statefulSet("9000000000000"))
+	assert.ErrorContains(t, err, "overflows")
+}
+
+func Test_ExtractStorageRequests_CollidingNames(t *testing.T) {
+	input := `apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+spec:
+  resources:
+    requests:
+      storage: 5Gi
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  volumeClaimTemplates:
+    - metadata:
+        name: data
+      spec:
+        resources:
+          requests:
+            storage: 1Gi
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: cache
+spec:
+  volumeClaimTemplates:
+    - metadata:
+        name: data
+      spec:
+        resources:
+          requests:
+            storage: 2Gi
+`
+
+	requests, err := ExtractStorageRequests([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"data":       "5Gi",
+		"db/data":    "1Gi",
+		"cache/data": "2Gi",
+	}, requests)
+}
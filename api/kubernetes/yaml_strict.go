@@ -0,0 +1,67 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	kjson "sigs.k8s.io/json"
+)
+
+// validateStrict returns an error if a resource of a document holds fields that are unknown to its kind.
+// The resources of the builtin kinds are checked against their whole definition, while only the metadata
+// is checked for the other kinds, such as custom resources, whose schema isn't known.
+func validateStrict(doc map[string]interface{}) error {
+	return visitResources(doc, func(resource map[string]interface{}) error {
+		if err := validateStrictResource(resource); err != nil {
+			return errors.Wrapf(err, "invalid %s", resourceKey(resource))
+		}
+		return nil
+	})
+}
+
+func validateStrictResource(resource map[string]interface{}) error {
+	apiVersion, _ := resource["apiVersion"].(string)
+	kind, _ := resource["kind"].(string)
+
+	if gv, err := schema.ParseGroupVersion(apiVersion); err == nil && kind != "" {
+		if obj, err := scheme.Scheme.New(gv.WithKind(kind)); err == nil {
+			return decodeStrict(resource, obj)
+		}
+	}
+
+	metadata, ok := resource["metadata"]
+	if !ok {
+		return nil
+	}
+
+	return decodeStrict(metadata, &metav1.ObjectMeta{})
+}
+
+// decodeStrict decodes value into target through its JSON representation, failing on unknown fields.
+// Field names are matched case-sensitively like the api server does, so that "Labels" is not taken for "labels".
+func decodeStrict(value interface{}, target interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	strictErrs, err := kjson.UnmarshalStrict(raw, target, kjson.DisallowUnknownFields)
+	if err != nil {
+		return err
+	}
+
+	if len(strictErrs) > 0 {
+		problems := make([]string, 0, len(strictErrs))
+		for _, e := range strictErrs {
+			problems = append(problems, e.Error())
+		}
+
+		return errors.New(strings.Join(problems, ", "))
+	}
+
+	return nil
+}
@@ -1,6 +1,9 @@
 package kubernetes
 
 import (
+	"bytes"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -51,6 +54,11 @@ spec:
     metadata:
       labels:
         app: busybox
+        io.portainer.kubernetes.application.kind: git
+        io.portainer.kubernetes.application.name: best-name
+        io.portainer.kubernetes.application.owner: best-owner
+        io.portainer.kubernetes.application.stack: best-name
+        io.portainer.kubernetes.application.stackid: "123"
     spec:
       containers:
         - image: busybox
@@ -99,6 +107,11 @@ spec:
     metadata:
       labels:
         app: busybox
+        io.portainer.kubernetes.application.kind: git
+        io.portainer.kubernetes.application.name: best-name
+        io.portainer.kubernetes.application.owner: best-owner
+        io.portainer.kubernetes.application.stack: best-name
+        io.portainer.kubernetes.application.stackid: "123"
     spec:
       containers:
         - image: busybox
@@ -211,6 +224,11 @@ items:
           creationTimestamp: null
           labels:
             io.kompose.service: redis
+            io.portainer.kubernetes.application.kind: git
+            io.portainer.kubernetes.application.name: best-name
+            io.portainer.kubernetes.application.owner: best-owner
+            io.portainer.kubernetes.application.stack: best-name
+            io.portainer.kubernetes.application.stackid: "123"
     status: {}
   - apiVersion: apps/v1
     kind: Deployment
@@ -235,6 +253,11 @@ items:
           creationTimestamp: null
           labels:
             io.kompose.service: web
+            io.portainer.kubernetes.application.kind: git
+            io.portainer.kubernetes.application.name: best-name
+            io.portainer.kubernetes.application.owner: best-owner
+            io.portainer.kubernetes.application.stack: best-name
+            io.portainer.kubernetes.application.stackid: "123"
     status: {}
 kind: List
 metadata: {}
@@ -314,6 +337,11 @@ spec:
     metadata:
       labels:
         app: busybox
+        io.portainer.kubernetes.application.kind: git
+        io.portainer.kubernetes.application.name: best-name
+        io.portainer.kubernetes.application.owner: best-owner
+        io.portainer.kubernetes.application.stack: best-name
+        io.portainer.kubernetes.application.stackid: "123"
     spec:
       containers:
         - image: busybox
@@ -359,6 +387,11 @@ spec:
     metadata:
       labels:
         app: busybox
+        io.portainer.kubernetes.application.kind: git
+        io.portainer.kubernetes.application.name: best-name
+        io.portainer.kubernetes.application.owner: best-owner
+        io.portainer.kubernetes.application.stack: best-name
+        io.portainer.kubernetes.application.stackid: "123"
     spec:
       containers:
         - image: busybox
@@ -569,6 +602,8 @@ spec:
         app.kubernetes.io/managed-by: Helm
         app.kubernetes.io/name: nginx
         helm.sh/chart: nginx-9.5.4
+        io.portainer.kubernetes.application.name: best-name
+        io.portainer.kubernetes.application.owner: best-owner
     spec:
       automountServiceAccountToken: false
       containers:
@@ -656,6 +691,57 @@ metadata:
 			name: "invalid namespace",
 			input: `apiVersion: v1
 kind: Namespace
+`,
+			want: "",
+		},
+		{
+			name: "list of resources in the same namespace",
+			input: `apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Namespace
+    metadata:
+      name: frontend
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+      namespace: frontend
+kind: List
+`,
+			want: "frontend",
+		},
+		{
+			name: "list with cluster-scoped resources",
+			input: `apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+      namespace: x
+  - apiVersion: rbac.authorization.k8s.io/v1
+    kind: ClusterRole
+    metadata:
+      name: reader
+kind: List
+`,
+			want: "x",
+		},
+		{
+			name: "empty list",
+			input: `apiVersion: v1
+items: []
+kind: List
+`,
+			want: "",
+		},
+		{
+			name: "namespace with a generated name",
+			input: `apiVersion: v1
+kind: Namespace
+metadata:
+  generateName: test-
 `,
 			want: "",
 		},
@@ -697,6 +783,26 @@ kind: Namespace
 `,
 			want: []string{`apiVersion: v1
 kind: Namespace
+`},
+		},
+		{
+			name: "trailing separators and empty documents",
+			input: `---
+apiVersion: v1
+kind: Namespace
+---
+---
+
+---
+apiVersion: v1
+kind: Service
+---
+---
+`,
+			want: []string{`apiVersion: v1
+kind: Namespace
+`, `apiVersion: v1
+kind: Service
 `},
 		},
 	}
@@ -705,9 +811,1592 @@ kind: Namespace
 		t.Run(tt.name, func(t *testing.T) {
 			results, err := ExtractDocuments([]byte(tt.input), nil)
 			assert.NoError(t, err)
+			assert.Len(t, results, len(tt.want))
 			for i := range results {
 				assert.Equal(t, tt.want[i], string(results[i]))
 			}
 		})
 	}
 }
+
+func Test_RemoveAppLabels(t *testing.T) {
+	labels := KubeAppLabels{
+		StackID:   123,
+		StackName: "best-name",
+		Owner:     "best-owner",
+		Kind:      "git",
+	}
+
+	labelKeys := []string{
+		labelPortainerAppStackID,
+		labelPortainerAppStack,
+		labelPortainerAppName,
+		labelPortainerAppOwner,
+		labelPortainerAppKind,
+	}
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name: "multiple documents with and without labels",
+			input: `apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    io.kompose.service: web
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: busybox
+`,
+		},
+		{
+			name: "list of resources",
+			input: `apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      labels:
+        io.kompose.service: web
+      name: web
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+kind: List
+metadata: {}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labeled, err := AddAppLabels([]byte(tt.input), labels.ToMap())
+			assert.NoError(t, err)
+			assert.NotEqual(t, tt.input, string(labeled))
+
+			result, err := RemoveAppLabels(labeled, labelKeys)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.input, string(result))
+		})
+	}
+}
+
+func Test_StripPortainerLabels(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: web
+  name: web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+        - image: nginx:1.25
+          name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+	stackLabels := &KubeAppLabels{StackID: 123, StackName: "best-name", Owner: "best-owner", Kind: "git"}
+	labeled, err := AddAppLabels([]byte(input), stackLabels.ToMap())
+	assert.NoError(t, err)
+
+	labeled, err = AddAppLabels(labeled, GetHelmAppLabels("best-name", "best-owner"))
+	assert.NoError(t, err)
+
+	result, err := StripPortainerLabels(labeled)
+	assert.NoError(t, err)
+	assert.Equal(t, input, string(result))
+}
+
+func Test_StripPortainerLabels_SelectorLabels(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: x
+    io.portainer.kubernetes.application.stack: best-name
+  name: x
+spec:
+  selector:
+    matchLabels:
+      io.portainer.kubernetes.application.name: x
+  template:
+    metadata:
+      labels:
+        io.portainer.kubernetes.application.name: x
+        io.portainer.kubernetes.application.stack: best-name
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  template:
+    metadata:
+      labels:
+        io.portainer.kubernetes.application.stack: best-name
+`
+	expected := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: x
+spec:
+  selector:
+    matchLabels:
+      io.portainer.kubernetes.application.name: x
+  template:
+    metadata:
+      labels:
+        io.portainer.kubernetes.application.name: x
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  template: {}
+`
+
+	result, err := StripPortainerLabels([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_AddAppAnnotations(t *testing.T) {
+	annotations := map[string]string{
+		"fluxcd.io/automated":    "true",
+		"portainer.io/owner":     "Jane Doe <jane@example.com>",
+		"argocd.argoproj.io/app": "best-name",
+	}
+
+	input := `apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      annotations:
+        foo: bar
+      name: web
+kind: List
+metadata: {}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: busybox
+  name: busybox
+`
+	expected := `apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      annotations:
+        argocd.argoproj.io/app: best-name
+        fluxcd.io/automated: "true"
+        foo: bar
+        portainer.io/owner: Jane Doe <jane@example.com>
+      name: web
+kind: List
+metadata: {}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    argocd.argoproj.io/app: best-name
+    fluxcd.io/automated: "true"
+    portainer.io/owner: Jane Doe <jane@example.com>
+  labels:
+    app: busybox
+  name: busybox
+`
+
+	result, err := AddAppAnnotations([]byte(input), annotations)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_AddAppLabels_PodTemplates(t *testing.T) {
+	labels := GetHelmAppLabels("best-name", "best-owner")
+
+	input := `apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  selector:
+    matchLabels:
+      app: db
+  template:
+    metadata:
+      labels:
+        app: db
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - image: busybox
+              name: backup
+  schedule: '@daily'
+`
+	expected := `apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: db
+spec:
+  selector:
+    matchLabels:
+      app: db
+  template:
+    metadata:
+      labels:
+        app: db
+        io.portainer.kubernetes.application.name: best-name
+        io.portainer.kubernetes.application.owner: best-owner
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        metadata:
+          labels:
+            io.portainer.kubernetes.application.name: best-name
+            io.portainer.kubernetes.application.owner: best-owner
+        spec:
+          containers:
+            - image: busybox
+              name: backup
+  schedule: '@daily'
+`
+
+	result, err := AddAppLabels([]byte(input), labels)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_AddAppLabels_PodTemplateSelectorLabels(t *testing.T) {
+	labels := (&KubeAppLabels{StackName: "mystack"}).ToMap()
+
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      io.portainer.kubernetes.application.name: web
+  template:
+    metadata:
+      labels:
+        io.portainer.kubernetes.application.name: web
+`
+	expected := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    io.portainer.kubernetes.application.kind: ""
+    io.portainer.kubernetes.application.name: mystack
+    io.portainer.kubernetes.application.owner: ""
+    io.portainer.kubernetes.application.stack: mystack
+    io.portainer.kubernetes.application.stackid: "0"
+  name: web
+spec:
+  selector:
+    matchLabels:
+      io.portainer.kubernetes.application.name: web
+  template:
+    metadata:
+      labels:
+        io.portainer.kubernetes.application.kind: ""
+        io.portainer.kubernetes.application.name: web
+        io.portainer.kubernetes.application.owner: ""
+        io.portainer.kubernetes.application.stack: mystack
+        io.portainer.kubernetes.application.stackid: "0"
+`
+
+	result, err := AddAppLabels([]byte(input), labels)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	result, err = AddAppLabelsWithOpts([]byte(input), labels, AddAppLabelsOpts{OnConflict: LabelConflictOverwrite})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_ExtractDocuments_InvalidYaml(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+---
+metadata: [
+`
+
+	_, err := ExtractDocuments([]byte(input), nil)
+	assert.Error(t, err)
+
+	var docErr *DocumentError
+	if assert.ErrorAs(t, err, &docErr) {
+		assert.Equal(t, 1, docErr.Index)
+		assert.Equal(t, 4, docErr.Line)
+	}
+	assert.Contains(t, err.Error(), "failed to unmarshal yaml manifest")
+}
+
+func Test_ExtractDocuments_PostProcessError(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+---
+apiVersion: v1
+kind: Namespace
+`
+
+	_, err := ExtractDocuments([]byte(input), func(yamlDoc interface{}) error {
+		if yamlDoc.(map[string]interface{})["kind"] == "Namespace" {
+			return errors.New("namespaces are not allowed")
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "failed to post process yaml document: document 1: namespaces are not allowed")
+
+	var docErr *DocumentError
+	if assert.ErrorAs(t, err, &docErr) {
+		assert.Equal(t, 1, docErr.Index)
+		assert.Equal(t, 0, docErr.Line)
+	}
+}
+
+func Test_SanitizeLabelValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "valid value",
+			input: "best-owner",
+			want:  "best-owner",
+		},
+		{
+			name:  "invalid characters",
+			input: "john doe@example.com",
+			want:  "john.doe.example.com",
+		},
+		{
+			name:  "over-long value",
+			input: "cn=john.doe,ou=engineering,ou=people,dc=example,dc=com,dc=internal",
+			want:  "cn.john.doe.ou.engineering.ou.people.dc.example.dc.com.dc.inter",
+		},
+		{
+			name:  "truncated value ending with a period",
+			input: "cn=john.doe,ou=engineering,ou=people,dc=example,dc=com,dc=inter.nal",
+			want:  "cn.john.doe.ou.engineering.ou.people.dc.example.dc.com.dc.inter",
+		},
+		{
+			name:  "value ending with a period",
+			input: "best-owner.",
+			want:  "best-owner",
+		},
+		{
+			name:  "value starting with invalid characters",
+			input: "_-best-owner",
+			want:  "best-owner",
+		},
+		{
+			name:  "value empty after sanitizing",
+			input: "@@@",
+			want:  "",
+		},
+		{
+			name:  "accented letters",
+			input: "José Müller-Françoise",
+			want:  "Jose.Muller-Francoise",
+		},
+		{
+			name:  "letters without decomposition",
+			input: "Łukasz Størmer Straße",
+			want:  "Lukasz.Stormer.Strasse",
+		},
+		{
+			name:  "non latin characters",
+			input: "user-山田",
+			want:  "user",
+		},
+		{
+			name:  "over-long value with multi-byte characters",
+			input: strings.Repeat("é", 70),
+			want:  strings.Repeat("e", 63),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := SanitizeLabelValue(tt.input)
+			assert.Equal(t, tt.want, result)
+			assert.LessOrEqual(t, len(result), labelValueMaxLength)
+		})
+	}
+}
+
+func Test_labelKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		valid  bool
+		want   string
+	}{
+		{
+			name:   "default prefix",
+			prefix: DefaultLabelPrefix,
+			valid:  true,
+			want:   "io.portainer.kubernetes.application.stackid",
+		},
+		{
+			name:   "prefix with a trailing period",
+			prefix: "com.example.app.",
+			valid:  true,
+			want:   "com.example.app.stackid",
+		},
+		{
+			name:   "dns prefix",
+			prefix: "example.com/",
+			valid:  true,
+			want:   "example.com/stackid",
+		},
+		{
+			name:   "uppercase dns prefix is kept as is",
+			prefix: "Example.com/",
+			want:   "Example.com/stackid",
+		},
+		{
+			name:   "invalid characters are kept as is",
+			prefix: "my company",
+			want:   "my company.stackid",
+		},
+		{
+			name:   "long prefix is not truncated",
+			prefix: strings.Repeat("a", 60),
+			want:   strings.Repeat("a", 60) + ".stackid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := labelKey(tt.prefix, labelSuffixStackID)
+			assert.Equal(t, tt.want, key)
+
+			err := ValidateLabelKeys(map[string]string{key: "1"})
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func Test_ToMapWithPrefix(t *testing.T) {
+	labels := KubeAppLabels{
+		StackID:   123,
+		StackName: "best-name",
+		Owner:     "best owner",
+		Kind:      "git",
+	}
+
+	result, err := labels.ToMapWithPrefix(DefaultLabelPrefix)
+	assert.NoError(t, err)
+	assert.Equal(t, labels.ToMap(), result)
+
+	result, err = labels.ToMapWithPrefix("com.example.app.")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"com.example.app.stackid": "123",
+		"com.example.app.stack":   "best-name",
+		"com.example.app.name":    "best-name",
+		"com.example.app.owner":   "best.owner",
+		"com.example.app.kind":    "git",
+	}, result)
+
+	result, err = GetHelmAppLabelsWithPrefix("best-name", "best owner", "com.example.app")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"com.example.app.name":  "best-name",
+		"com.example.app.owner": "best.owner",
+	}, result)
+
+	result, err = GetHelmAppLabelsWithPrefix("best-name", "best owner", "example.com/")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"example.com/name":  "best-name",
+		"example.com/owner": "best.owner",
+	}, result)
+
+	_, err = GetHelmAppLabelsWithPrefix("best-name", "best owner", "Example.com/")
+	assert.ErrorContains(t, err, "invalid label prefix 'Example.com/'")
+}
+
+func Test_ToMapWithPrefix_LongPrefix(t *testing.T) {
+	labels := KubeAppLabels{StackID: 123, StackName: "best-name", Owner: "best-owner", Kind: "git"}
+
+	// 60 characters, the keys would be truncated to the same 63 characters without a slash
+	prefix := "com.example.white-label.distribution.kubernetes.application1"
+	assert.Len(t, prefix, 60)
+
+	_, err := labels.ToMapWithPrefix(prefix)
+	assert.ErrorContains(t, err, "invalid label prefix")
+	assert.ErrorContains(t, err, prefix+".stackid")
+
+	_, err = GetHelmAppLabelsWithPrefix("best-name", "best-owner", prefix)
+	assert.ErrorContains(t, err, "invalid label prefix")
+
+	// the same prefix is valid as the dns prefix of the keys
+	result, err := labels.ToMapWithPrefix(prefix + "/")
+	assert.NoError(t, err)
+	assert.Len(t, result, 5)
+	assert.Equal(t, "123", result[prefix+"/stackid"])
+	assert.Equal(t, "best-name", result[prefix+"/stack"])
+}
+
+func Test_KubeAppLabelsFromMap(t *testing.T) {
+	labels := KubeAppLabels{
+		StackID:   123,
+		StackName: "best-name",
+		Owner:     "best-owner",
+		Kind:      "git",
+	}
+
+	result, err := KubeAppLabelsFromMap(labels.ToMap())
+	assert.NoError(t, err)
+	assert.Equal(t, &labels, result)
+
+	result, err = KubeAppLabelsFromMap(map[string]string{labelPortainerAppStackID: "7"})
+	assert.NoError(t, err)
+	assert.Equal(t, &KubeAppLabels{StackID: 7}, result)
+
+	_, err = KubeAppLabelsFromMap(map[string]string{labelPortainerAppStackID: "abc"})
+	assert.Error(t, err)
+
+	_, err = KubeAppLabelsFromMap(map[string]string{labelPortainerAppOwner: "best-owner"})
+	assert.Error(t, err)
+}
+
+func Test_GetOwner(t *testing.T) {
+	assert.Equal(t, "best-owner", GetOwner(GetHelmAppLabels("best-name", "best-owner")))
+	assert.Equal(t, "", GetOwner(map[string]string{"app": "web"}))
+	assert.Equal(t, "", GetOwner(nil))
+}
+
+func Test_StackSelectors(t *testing.T) {
+	assert.Equal(t, "io.portainer.kubernetes.application.stackid=42", StackLabelSelector(42))
+	assert.Equal(t, "io.portainer.kubernetes.application.name=best-name", StackNameSelector("best-name"))
+}
+
+func Test_GetStackID(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   int
+		wantOk bool
+	}{
+		{
+			name:   "numeric stack id",
+			labels: (&KubeAppLabels{StackID: 42, StackName: "web"}).ToMap(),
+			want:   42,
+			wantOk: true,
+		},
+		{
+			name:   "missing stack id",
+			labels: map[string]string{labelPortainerAppName: "web"},
+		},
+		{
+			name:   "non numeric stack id",
+			labels: map[string]string{labelPortainerAppStackID: "web"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stackID, ok := GetStackID(tt.labels)
+			assert.Equal(t, tt.want, stackID)
+			assert.Equal(t, tt.wantOk, ok)
+		})
+	}
+}
+
+func Test_StreamDocuments(t *testing.T) {
+	labels := GetHelmAppLabels("best-name", "best-owner")
+
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+kind: List
+---
+`
+
+	postProcess := func(yamlDoc interface{}) error {
+		addResourceLabels(yamlDoc, labels)
+		return nil
+	}
+
+	var out bytes.Buffer
+	err := StreamDocuments(strings.NewReader(input), &out, postProcess)
+	assert.NoError(t, err)
+
+	expected, err := AddAppLabels([]byte(input), labels)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), out.String())
+
+	out.Reset()
+	err = StreamDocuments(strings.NewReader("kind: [\n"), &out, postProcess)
+	assert.Error(t, err)
+}
+
+func Test_AddAppLabels_BlockScalars(t *testing.T) {
+	input := `apiVersion: v1
+data:
+  entrypoint.sh: |
+    #!/bin/sh
+    set -e
+
+    exec "$@"
+kind: ConfigMap
+metadata:
+  name: scripts
+`
+	expected := `apiVersion: v1
+data:
+  entrypoint.sh: |
+    #!/bin/sh
+    set -e
+
+    exec "$@"
+kind: ConfigMap
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: scripts
+`
+
+	result, err := AddAppLabels([]byte(input), GetHelmAppLabels("best-name", "best-owner"))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_AddAppLabels_JSON(t *testing.T) {
+	labels := GetHelmAppLabels("best-name", "best-owner")
+
+	tests := []struct {
+		name       string
+		input      string
+		wantOutput string
+	}{
+		{
+			name: "json array",
+			input: `[
+  {"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web"}, "spec": {"ports": [{"port": 80}]}},
+  {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "config", "labels": {"app": "web"}}, "data": {"port": "8080"}}
+]`,
+			wantOutput: `apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: web
+spec:
+  ports:
+    - port: 80
+---
+apiVersion: v1
+data:
+  port: "8080"
+kind: ConfigMap
+metadata:
+  labels:
+    app: web
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: config
+`,
+		},
+		{
+			name:  "json object",
+			input: `{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "test"}}`,
+			wantOutput: `apiVersion: v1
+kind: Namespace
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: test
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := AddAppLabels([]byte(tt.input), labels)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantOutput, string(result))
+		})
+	}
+}
+
+func Test_AddAppLabelsExcept(t *testing.T) {
+	labels := GetHelmAppLabels("best-name", "best-owner")
+
+	input := `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Namespace
+    metadata:
+      name: frontend
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+kind: List
+`
+	expected := `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Namespace
+    metadata:
+      name: frontend
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      labels:
+        io.portainer.kubernetes.application.name: best-name
+        io.portainer.kubernetes.application.owner: best-owner
+      name: web
+kind: List
+`
+
+	result, err := AddAppLabelsExcept([]byte(input), labels, []string{"customresourcedefinition", "Namespace"})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_AddAppLabelsForKinds(t *testing.T) {
+	labels := GetHelmAppLabels("best-name", "best-owner")
+
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+  - apiVersion: v1
+    kind: service
+    metadata:
+      name: web
+kind: List
+`
+	expected := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+        io.portainer.kubernetes.application.name: best-name
+        io.portainer.kubernetes.application.owner: best-owner
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+  - apiVersion: v1
+    kind: service
+    metadata:
+      labels:
+        io.portainer.kubernetes.application.name: best-name
+        io.portainer.kubernetes.application.owner: best-owner
+      name: web
+kind: List
+`
+
+	result, err := AddAppLabelsForKinds([]byte(input), labels, []string{"Deployment", "Service"})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	_, err = AddAppLabelsForKinds([]byte(input), labels, nil)
+	assert.Error(t, err)
+}
+
+func Test_MergeManifests(t *testing.T) {
+	first := `---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: frontend
+---
+`
+	second := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config`
+
+	expected := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: frontend
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+	result, err := MergeManifests([]byte(first), []byte(""), []byte(second))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	_, err = MergeManifests([]byte(first), []byte("kind: [invalid"))
+	assert.ErrorContains(t, err, "failed to extract documents from manifest 1")
+}
+
+func Test_AddAppLabelsToDocument(t *testing.T) {
+	labels := GetHelmAppLabels("best-name", "best-owner")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name: "single resource",
+			input: `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+---
+`,
+			expected: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: config
+`,
+		},
+		{
+			name: "list items are labeled",
+			input: `apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+kind: List
+`,
+			expected: `apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      labels:
+        io.portainer.kubernetes.application.name: best-name
+        io.portainer.kubernetes.application.owner: best-owner
+      name: web
+kind: List
+`,
+		},
+		{
+			name:     "empty document",
+			input:    "",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := AddAppLabelsToDocument([]byte(tt.input), labels)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(result))
+			assert.NotContains(t, string(result), "---")
+		})
+	}
+}
+
+func Test_AddAppLabelsToDocument_MultipleDocuments(t *testing.T) {
+	input := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n"
+
+	_, err := AddAppLabelsToDocument([]byte(input), map[string]string{"app": "web"})
+	assert.ErrorContains(t, err, "expected a single yaml document")
+
+	_, err = AddAppLabelsToDocument([]byte("kind: [\n"), map[string]string{"app": "web"})
+	assert.ErrorContains(t, err, "failed to unmarshal yaml document")
+}
+
+func Test_AddAppLabelsToDocument_TopLevelSequence(t *testing.T) {
+	input := `- apiVersion: v1
+  kind: Service
+  metadata:
+    name: web
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: config
+`
+	expected := `- apiVersion: v1
+  kind: Service
+  metadata:
+    labels:
+      app: web
+    name: web
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    labels:
+      app: web
+    name: config
+`
+
+	result, err := AddAppLabelsToDocument([]byte(input), map[string]string{"app": "web"})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	_, err = AddAppLabelsToDocument([]byte("just a string\n"), map[string]string{"app": "web"})
+	assert.ErrorContains(t, err, "expected a yaml mapping or sequence document")
+}
+
+func Test_AddAppLabels_MalformedMetadata(t *testing.T) {
+	labels := GetHelmAppLabels("best-name", "best-owner")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name: "metadata is a string",
+			input: `apiVersion: example.com/v1
+kind: Certificate
+metadata: web
+`,
+			expected: `apiVersion: example.com/v1
+kind: Certificate
+metadata: web
+`,
+		},
+		{
+			name: "labels is a list",
+			input: `apiVersion: example.com/v1
+kind: Certificate
+metadata:
+  labels:
+    - app
+  name: web
+`,
+			expected: `apiVersion: example.com/v1
+kind: Certificate
+metadata:
+  labels:
+    - app
+  name: web
+`,
+		},
+		{
+			name: "metadata is null",
+			input: `apiVersion: example.com/v1
+kind: Certificate
+metadata:
+`,
+			expected: `apiVersion: example.com/v1
+kind: Certificate
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+`,
+		},
+		{
+			name: "labels is null",
+			input: `apiVersion: example.com/v1
+kind: Certificate
+metadata:
+  labels:
+  name: web
+`,
+			expected: `apiVersion: example.com/v1
+kind: Certificate
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: web
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := AddAppLabels([]byte(tt.input), labels)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(result))
+		})
+	}
+}
+
+func Test_MalformedDocuments(t *testing.T) {
+	inputs := map[string]string{
+		"numeric kind":            "kind: 1\nmetadata:\n  name: web\n",
+		"list kind":               "kind: [List]\nitems: web\n",
+		"metadata is a string":    "kind: Service\nmetadata: web\n",
+		"metadata is a list":      "kind: Deployment\nmetadata:\n  - name\nspec:\n  template: web\n",
+		"labels is a list":        "kind: Deployment\nmetadata:\n  labels: [app]\nspec:\n  template:\n    metadata:\n      labels: 1\n",
+		"annotations is a string": "kind: Service\nmetadata:\n  annotations: web\n",
+		"items is a string":       "kind: List\nitems: web\n",
+		"items are scalars":       "kind: List\nitems: [1, web, null]\n",
+		"namespace is a list":     "kind: Service\nmetadata:\n  namespace: [web]\n",
+	}
+
+	labels := GetHelmAppLabels("best-name", "best-owner")
+
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			manifest := []byte(input)
+			assert.NotPanics(t, func() {
+				_, _ = AddAppLabels(manifest, labels)
+				_, _ = AddAppLabelsWithOpts(manifest, labels, AddAppLabelsOpts{OnConflict: LabelConflictError})
+				_, _ = RemoveAppLabels(manifest, []string{labelPortainerAppName})
+				_, _ = AddAppAnnotations(manifest, map[string]string{"a": "b"})
+				_, _ = GetNamespace(manifest)
+				_, _ = GetNamespaces(manifest)
+				_, _ = SetNamespace(manifest, "other")
+				_, _ = ListResources(manifest)
+				_ = ValidateManifest(manifest)
+			})
+		})
+	}
+}
+
+func Test_GetNamespace_ListInDifferentNamespaces(t *testing.T) {
+	input := `apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: web
+      namespace: frontend
+  - apiVersion: v1
+    kind: Service
+    metadata:
+      name: api
+kind: List
+`
+
+	_, err := GetNamespace([]byte(input))
+	assert.ErrorContains(t, err, "the items of the list are in different namespaces: 'frontend', ''")
+}
+
+func Test_GetNamespace_MalformedMetadata(t *testing.T) {
+	_, err := GetNamespace([]byte("kind: Service\nmetadata: web\n"))
+	assert.ErrorContains(t, err, "'metadata' field is not an object")
+}
+
+func Test_ToMultiDocument(t *testing.T) {
+	objects := []map[string]interface{}{
+		{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "config"},
+			"data":       map[string]string{"port": "8080"},
+		},
+		nil,
+		{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "web"},
+			"spec":       map[string]interface{}{"replicas": 2},
+		},
+	}
+
+	expected := `apiVersion: v1
+data:
+  port: "8080"
+kind: ConfigMap
+metadata:
+  name: config
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+`
+
+	result, err := ToMultiDocument(objects)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	// extracting the documents back gives the same encoding
+	docs, err := ExtractDocuments(result, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(bytes.Join(docs, []byte("---\n"))))
+
+	empty, err := ToMultiDocument(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func Test_NormalizeManifest(t *testing.T) {
+	first := `---
+kind: Deployment
+apiVersion: apps/v1
+metadata: {name: web, labels: {tier: frontend, app: web}}
+spec:
+    replicas: 2
+---
+`
+	second := `# the web deployment
+apiVersion: "apps/v1"
+kind: Deployment
+metadata:
+  labels:
+    app: web
+    tier: frontend
+  name: web
+spec:
+  replicas: 2
+---
+---
+`
+
+	expected := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: web
+    tier: frontend
+  name: web
+spec:
+  replicas: 2
+`
+
+	firstResult, err := NormalizeManifest([]byte(first))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(firstResult))
+
+	secondResult, err := NormalizeManifest([]byte(second))
+	assert.NoError(t, err)
+	assert.Equal(t, string(firstResult), string(secondResult))
+
+	again, err := NormalizeManifest(firstResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(firstResult), string(again))
+}
+
+func Test_ManifestHash(t *testing.T) {
+	input := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  port: "8080"
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+
+	hash, err := ManifestHash([]byte(input))
+	assert.NoError(t, err)
+	assert.Len(t, hash, 64)
+
+	equivalent := []string{
+		// indentation, key order, comments and empty documents
+		`---
+# the configuration
+kind: ConfigMap
+apiVersion: v1
+data:
+    port: '8080'
+metadata:
+    name: config
+---
+---
+metadata: {name: web}
+kind: Service
+apiVersion: v1
+`,
+	}
+	for _, other := range equivalent {
+		otherHash, err := ManifestHash([]byte(other))
+		assert.NoError(t, err)
+		assert.Equal(t, hash, otherHash)
+	}
+
+	different := []string{
+		strings.Replace(input, `"8080"`, "8080", 1),
+		strings.Replace(input, "name: web", "name: api", 1),
+		strings.Replace(input, "  port: \"8080\"\n", "  port: \"8080\"\n  host: localhost\n", 1),
+		// document order
+		"apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\ndata:\n  port: \"8080\"\n",
+	}
+	for _, other := range different {
+		otherHash, err := ManifestHash([]byte(other))
+		assert.NoError(t, err)
+		assert.NotEqual(t, hash, otherHash, other)
+	}
+
+	_, err = ManifestHash([]byte("kind: [\n"))
+	assert.Error(t, err)
+}
+
+func Test_WalkResources(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: secret
+kind: List
+`
+
+	visited := make([]string, 0)
+	err := WalkResources([]byte(input), func(resource map[string]interface{}) error {
+		visited = append(visited, resource["kind"].(string))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Service", "ConfigMap", "Secret"}, visited)
+
+	stop := errors.New("stop")
+	visited = visited[:0]
+	err = WalkResources([]byte(input), func(resource map[string]interface{}) error {
+		visited = append(visited, resource["kind"].(string))
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, []string{"Service"}, visited)
+}
+
+func Test_AddAppLabels_TopLevelSequence(t *testing.T) {
+	input := `- apiVersion: v1
+  kind: Service
+  metadata:
+    name: web
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: api
+`
+	expected := `apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: api
+`
+
+	result, err := AddAppLabels([]byte(input), GetHelmAppLabels("best-name", "best-owner"))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	resources, err := ListResources([]byte(input))
+	assert.NoError(t, err)
+	assert.Len(t, resources, 2)
+}
+
+func Test_ExtractDocumentsWithSource(t *testing.T) {
+	input := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+	docs, err := ExtractDocumentsWithSource([]byte(input), "manifests/web.yaml", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`# source: manifests/web.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`, `# source: manifests/web.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`}, toStrings(docs))
+
+	docs, err = ExtractDocumentsWithSource([]byte(input), "web\nkind: Secret", nil)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(docs[0]), "# source: web kind: Secret\n"))
+
+	// the source comment is dropped when the documents are extracted again
+	reextracted, err := ExtractDocuments(bytes.Join(docs, []byte("---\n")), nil)
+	assert.NoError(t, err)
+	assert.Len(t, reextracted, 2)
+	assert.NotContains(t, string(reextracted[0]), "# source")
+}
+
+func Test_AddAppLabels_QuotedScalars(t *testing.T) {
+	input := `apiVersion: v1
+data:
+  port: "8080"
+  enabled: "true"
+  nothing: "null"
+  tilde: "~"
+  single: '8080'
+  tagged: !!str 8080
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+	result, err := AddAppLabels([]byte(input), GetHelmAppLabels("best-name", "best-owner"))
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+data:
+  enabled: "true"
+  nothing: "null"
+  port: "8080"
+  single: "8080"
+  tagged: "8080"
+  tilde: "~"
+kind: ConfigMap
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: config
+`, string(result))
+
+	preserved, err := ExtractDocumentsPreserving([]byte(input), func(doc interface{}) error {
+		return addResourceLabels(doc, GetHelmAppLabels("best-name", "best-owner"))
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+data:
+  port: "8080"
+  enabled: "true"
+  nothing: "null"
+  tilde: "~"
+  single: '8080'
+  tagged: !!str 8080
+kind: ConfigMap
+metadata:
+  name: config
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+`, string(preserved[0]))
+}
+
+func Test_AddAppLabels_YAML11Booleans(t *testing.T) {
+	input := `apiVersion: v1
+data:
+  debug: off
+  enabled: on
+  legacy: Yes
+  prompt: y
+  verbose: no
+  answer: n
+  real: true
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+	result, err := AddAppLabels([]byte(input), GetHelmAppLabels("best-name", "best-owner"))
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: v1
+data:
+  answer: "n"
+  debug: "off"
+  enabled: "on"
+  legacy: "Yes"
+  prompt: "y"
+  real: true
+  verbose: "no"
+kind: ConfigMap
+metadata:
+  labels:
+    io.portainer.kubernetes.application.name: best-name
+    io.portainer.kubernetes.application.owner: best-owner
+  name: config
+`, string(result))
+
+	var values map[string]interface{}
+	err = WalkResources([]byte(input), func(resource map[string]interface{}) error {
+		values = resource["data"].(map[string]interface{})
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "on", values["enabled"])
+	assert.Equal(t, "off", values["debug"])
+	assert.Equal(t, true, values["real"])
+
+	preserved, err := ExtractDocumentsPreserving([]byte(input), func(doc interface{}) error {
+		return addResourceLabels(doc, GetHelmAppLabels("best-name", "best-owner"))
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, string(preserved[0]), "  debug: off\n  enabled: on\n")
+}
+
+func Test_TransformResources(t *testing.T) {
+	input := `apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: tls
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+kind: List
+`
+	expected := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: frontend
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+      namespace: frontend
+kind: List
+`
+
+	// drop the secrets and move everything else to the frontend namespace
+	result, err := TransformResources([]byte(input), func(resource map[string]interface{}) (bool, error) {
+		if matchesKind(resource, []string{"secret"}) {
+			return false, nil
+		}
+
+		resource["metadata"].(map[string]interface{})["namespace"] = "frontend"
+		return true, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	_, err = TransformResources([]byte(input), func(resource map[string]interface{}) (bool, error) {
+		return false, errors.New("boom")
+	})
+	assert.ErrorContains(t, err, "boom")
+}
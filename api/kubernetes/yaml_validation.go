@@ -0,0 +1,96 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ValidateManifest checks that every document of a manifest defines a non-empty apiVersion, kind and metadata.name.
+// A metadata.generateName satisfies the name requirement as the api server generates the name from it.
+// The items of a list are validated instead of the list itself.
+// All the problems found are reported in a single error, identifying each offending document by its index.
+func ValidateManifest(manifestYaml []byte) error {
+	problems := make([]string, 0)
+
+	err := forEachDocument(manifestYaml, func(index int, doc map[string]interface{}) error {
+		kind, _ := doc["kind"].(string)
+		if !strings.EqualFold(kind, "list") {
+			for _, problem := range validateResource(doc) {
+				problems = append(problems, fmt.Sprintf("document %d: %s", index, problem))
+			}
+			return nil
+		}
+
+		items, _ := doc["items"].([]interface{})
+		for i, item := range items {
+			resource, ok := item.(map[string]interface{})
+			if !ok {
+				problems = append(problems, fmt.Sprintf("document %d, item %d: not an object", index, i))
+				continue
+			}
+
+			for _, problem := range validateResource(resource) {
+				problems = append(problems, fmt.Sprintf("document %d, item %d: %s", index, i, problem))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("invalid kubernetes manifest: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// validateResource returns the problems found with the required fields of a resource
+func validateResource(resource map[string]interface{}) []string {
+	problems := make([]string, 0)
+
+	if apiVersion, _ := resource["apiVersion"].(string); apiVersion == "" {
+		problems = append(problems, "missing 'apiVersion' field")
+	}
+
+	if kind, _ := resource["kind"].(string); kind == "" {
+		problems = append(problems, "missing 'kind' field")
+	}
+
+	if resourceName(resource) == "" {
+		problems = append(problems, "missing 'metadata.name' field")
+	}
+
+	return problems
+}
+
+// FindDuplicateResources returns the kind/namespace/name keys of the resources that are defined more than once
+// in a manifest, in the order their first duplicate appears. Resources that don't specify a namespace are
+// considered to be in the default namespace and the items of a list are checked individually.
+// Resources that only set metadata.generateName get a unique name when created and are never duplicates.
+func FindDuplicateResources(manifestYaml []byte) ([]string, error) {
+	seen := make(map[string]int)
+	duplicates := make([]string, 0)
+
+	err := WalkResources(manifestYaml, func(resource map[string]interface{}) error {
+		if metadataString(resource, "name") == "" && metadataString(resource, "generateName") != "" {
+			return nil
+		}
+
+		key := resourceKey(resource)
+
+		seen[key]++
+		if seen[key] == 2 {
+			duplicates = append(duplicates, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return duplicates, nil
+}
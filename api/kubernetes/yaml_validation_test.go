@@ -0,0 +1,129 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ValidateManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name: "valid manifest",
+			input: `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: config
+kind: List
+`,
+		},
+		{
+			name: "generated name",
+			input: `apiVersion: batch/v1
+kind: Job
+metadata:
+  generateName: migrate-
+`,
+		},
+		{
+			name: "missing fields in several documents",
+			input: `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+kind: Deployment
+metadata:
+  name: ""
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+  - not-an-object
+kind: List
+`,
+			wantErr: "invalid kubernetes manifest: " +
+				"document 1: missing 'apiVersion' field; " +
+				"document 1: missing 'metadata.name' field; " +
+				"document 2, item 0: missing 'metadata.name' field; " +
+				"document 2, item 1: not an object",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateManifest([]byte(tt.input))
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func Test_FindDuplicateResources(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: default
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: other
+---
+apiVersion: v1
+items:
+  - apiVersion: v1
+    kind: Namespace
+    metadata:
+      name: other
+  - apiVersion: v1
+    kind: Namespace
+    metadata:
+      name: other
+  - apiVersion: apps/v1
+    kind: deployment
+    metadata:
+      name: web
+  - apiVersion: batch/v1
+    kind: Job
+    metadata:
+      generateName: migrate-
+  - apiVersion: batch/v1
+    kind: Job
+    metadata:
+      generateName: migrate-
+kind: List
+`
+
+	duplicates, err := FindDuplicateResources([]byte(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"deployment/default/web", "namespace//other"}, duplicates)
+}
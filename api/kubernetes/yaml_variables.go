@@ -0,0 +1,126 @@
+package kubernetes
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUndefinedVariable is returned by SubstituteVariablesWithOptions when a manifest references variables that
+// aren't defined and ErrorOnUndefined is set
+var ErrUndefinedVariable = errors.New("manifest references undefined variables")
+
+// SubstituteOptions controls how the variables of a manifest are substituted
+type SubstituteOptions struct {
+	// ErrorOnUndefined fails with ErrUndefinedVariable when a manifest references variables missing from vars,
+	// instead of leaving their tokens intact
+	ErrorOnUndefined bool
+}
+
+// SubstituteVariables replaces the ${NAME} and $NAME tokens of a manifest with the values of vars, before
+// any yaml parsing, the way docker compose interpolates variables. A variable name is made of letters, digits
+// and underscores and doesn't start with a digit. "$$" is an escaped "$" and is replaced with a single "$".
+// The tokens of the variables missing from vars, as well as the "$" that don't start a token, are left intact.
+func SubstituteVariables(manifestYaml []byte, vars map[string]string) ([]byte, error) {
+	return SubstituteVariablesWithOptions(manifestYaml, vars, SubstituteOptions{})
+}
+
+// SubstituteVariablesWithOptions replaces the variables of a manifest like SubstituteVariables does,
+// using the given options
+func SubstituteVariablesWithOptions(manifestYaml []byte, vars map[string]string, opts SubstituteOptions) ([]byte, error) {
+	var out bytes.Buffer
+	out.Grow(len(manifestYaml))
+
+	undefined := make(map[string]bool)
+	for i := 0; i < len(manifestYaml); {
+		if manifestYaml[i] != '$' {
+			out.WriteByte(manifestYaml[i])
+			i++
+			continue
+		}
+
+		if i+1 < len(manifestYaml) && manifestYaml[i+1] == '$' {
+			out.WriteByte('$')
+			i += 2
+			continue
+		}
+
+		name, size := variableToken(manifestYaml[i:])
+		if size == 0 {
+			out.WriteByte('$')
+			i++
+			continue
+		}
+
+		if value, ok := vars[name]; ok {
+			out.WriteString(value)
+		} else {
+			undefined[name] = true
+			out.Write(manifestYaml[i : i+size])
+		}
+		i += size
+	}
+
+	if opts.ErrorOnUndefined && len(undefined) > 0 {
+		names := make([]string, 0, len(undefined))
+		for name := range undefined {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return nil, errors.Wrap(ErrUndefinedVariable, strings.Join(names, ", "))
+	}
+
+	return out.Bytes(), nil
+}
+
+// variableToken returns the name of the variable token s starts with, which is either ${NAME} or $NAME,
+// along with the size of the token. The size is zero when s doesn't start with a valid token.
+func variableToken(s []byte) (string, int) {
+	if len(s) > 1 && s[1] == '{' {
+		end := bytes.IndexByte(s, '}')
+		if end < 0 || !isVariableName(s[2:end]) {
+			return "", 0
+		}
+
+		return string(s[2:end]), end + 1
+	}
+
+	end := 1
+	for end < len(s) && isVariableNameByte(s[end], end == 1) {
+		end++
+	}
+
+	if end == 1 {
+		return "", 0
+	}
+
+	return string(s[1:end]), end
+}
+
+func isVariableName(name []byte) bool {
+	if len(name) == 0 {
+		return false
+	}
+
+	for i, c := range name {
+		if !isVariableNameByte(c, i == 0) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isVariableNameByte(c byte, first bool) bool {
+	switch {
+	case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	}
+
+	return false
+}
@@ -0,0 +1,68 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SubstituteVariables(t *testing.T) {
+	vars := map[string]string{
+		"IMAGE":    "nginx:1.25",
+		"REPLICAS": "3",
+		"APP_NAME": "web",
+		"EMPTY":    "",
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "braced and plain tokens are replaced",
+			input:    "image: ${IMAGE}\nreplicas: $REPLICAS\nname: $APP_NAME-frontend\n",
+			expected: "image: nginx:1.25\nreplicas: 3\nname: web-frontend\n",
+		},
+		{
+			name:     "tokens inside a value are replaced",
+			input:    "command: echo ${APP_NAME}${EMPTY}:$REPLICAS\n",
+			expected: "command: echo web:3\n",
+		},
+		{
+			name:     "undefined variables are left intact",
+			input:    "value: ${MISSING} $MISSING ${IMAGE}\n",
+			expected: "value: ${MISSING} $MISSING nginx:1.25\n",
+		},
+		{
+			name:     "escaped dollars become literal dollars",
+			input:    "price: $$5\nliteral: $${IMAGE}\nvar: $$$IMAGE\n",
+			expected: "price: $5\nliteral: ${IMAGE}\nvar: $nginx:1.25\n",
+		},
+		{
+			name:     "dollars that don't start a token are kept",
+			input:    "a: $\nb: $1\nc: ${1X}\nd: ${IMAGE\ne: ${}\n",
+			expected: "a: $\nb: $1\nc: ${1X}\nd: ${IMAGE\ne: ${}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := SubstituteVariables([]byte(tt.input), vars)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(out))
+		})
+	}
+}
+
+func Test_SubstituteVariablesWithOptions_ErrorOnUndefined(t *testing.T) {
+	opts := SubstituteOptions{ErrorOnUndefined: true}
+
+	_, err := SubstituteVariablesWithOptions([]byte("a: ${B_VAR}\nb: $A_VAR $B_VAR\nc: $${ESCAPED}\n"), nil, opts)
+	assert.ErrorIs(t, err, ErrUndefinedVariable)
+	assert.ErrorContains(t, err, "A_VAR, B_VAR")
+
+	out, err := SubstituteVariablesWithOptions([]byte("a: ${A}\nb: $${B}\n"), map[string]string{"A": "1"}, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "a: 1\nb: ${B}\n", string(out))
+}
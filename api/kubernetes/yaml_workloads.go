@@ -0,0 +1,333 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// podTemplate returns the pod template of a workload resource.
+// It returns false if the resource is not a workload or does not define a pod template.
+func podTemplate(obj map[string]interface{}) (map[string]interface{}, bool) {
+	kind, _ := obj["kind"].(string)
+
+	switch strings.ToLower(kind) {
+	case "deployment", "statefulset", "daemonset", "replicaset", "job":
+		return nestedMap(obj, "spec", "template")
+	case "cronjob":
+		return nestedMap(obj, "spec", "jobTemplate", "spec", "template")
+	}
+
+	return nil, false
+}
+
+// workloadSelector returns the spec.selector.matchLabels of a workload, the labels its pod template has to keep
+func workloadSelector(obj map[string]interface{}) map[string]interface{} {
+	kind, _ := obj["kind"].(string)
+
+	var matchLabels map[string]interface{}
+	switch strings.ToLower(kind) {
+	case "deployment", "statefulset", "daemonset", "replicaset", "job":
+		matchLabels, _ = nestedMap(obj, "spec", "selector", "matchLabels")
+	case "cronjob":
+		matchLabels, _ = nestedMap(obj, "spec", "jobTemplate", "spec", "selector", "matchLabels")
+	}
+
+	return matchLabels
+}
+
+// SetReplicas sets spec.replicas to replicas on every Deployment, StatefulSet and ReplicaSet of a manifest,
+// adding the field when it is missing. DaemonSets, Jobs and the other resources are left untouched.
+func SetReplicas(manifestYaml []byte, replicas int) ([]byte, error) {
+	if replicas < 0 {
+		return nil, errors.Errorf("invalid replica count %d, it cannot be negative", replicas)
+	}
+
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		if !matchesKind(resource, []string{"deployment", "statefulset", "replicaset"}) {
+			return nil
+		}
+
+		spec, ok := resource["spec"].(map[string]interface{})
+		if !ok {
+			spec = make(map[string]interface{})
+			resource["spec"] = spec
+		}
+		spec["replicas"] = replicas
+
+		return nil
+	})
+}
+
+// AddImagePullSecret adds secretName to the imagePullSecrets of the pod spec of every pod and workload of a manifest,
+// creating the list when it is missing. Pod specs already referencing the secret are left untouched.
+func AddImagePullSecret(manifestYaml []byte, secretName string) ([]byte, error) {
+	if secretName == "" {
+		return nil, errors.New("image pull secret name cannot be empty")
+	}
+
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		spec, ok := podSpec(resource)
+		if !ok {
+			return nil
+		}
+
+		secrets, _ := spec["imagePullSecrets"].([]interface{})
+		for _, s := range secrets {
+			if secret, ok := s.(map[string]interface{}); ok && secret["name"] == secretName {
+				return nil
+			}
+		}
+
+		spec["imagePullSecrets"] = append(secrets, map[string]interface{}{"name": secretName})
+		return nil
+	})
+}
+
+// AddNodeSelector merges selector into the nodeSelector of the pod spec of every pod and workload of a manifest,
+// creating it when it is missing. The other entries of existing node selectors are kept, while the ones
+// with a key of selector are set to its value.
+func AddNodeSelector(manifestYaml []byte, selector map[string]string) ([]byte, error) {
+	if len(selector) == 0 {
+		return nil, errors.New("node selector cannot be empty")
+	}
+
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		spec, ok := podSpec(resource)
+		if !ok {
+			return nil
+		}
+
+		nodeSelector, ok := spec["nodeSelector"].(map[string]interface{})
+		if !ok {
+			nodeSelector = make(map[string]interface{}, len(selector))
+			spec["nodeSelector"] = nodeSelector
+		}
+
+		for k, v := range selector {
+			nodeSelector[k] = v
+		}
+		return nil
+	})
+}
+
+// AddToleration appends toleration to the tolerations of the pod spec of every pod and workload of a manifest,
+// creating the list when it is missing. Pod specs already holding a toleration with the same key, operator
+// and value are left untouched, a missing operator being the default Equal one.
+func AddToleration(manifestYaml []byte, toleration map[string]interface{}) ([]byte, error) {
+	if len(toleration) == 0 {
+		return nil, errors.New("toleration cannot be empty")
+	}
+
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		spec, ok := podSpec(resource)
+		if !ok {
+			return nil
+		}
+
+		tolerations, _ := spec["tolerations"].([]interface{})
+		for _, t := range tolerations {
+			if existing, ok := t.(map[string]interface{}); ok && tolerationID(existing) == tolerationID(toleration) {
+				return nil
+			}
+		}
+
+		added := make(map[string]interface{}, len(toleration))
+		for k, v := range toleration {
+			added[k] = v
+		}
+
+		spec["tolerations"] = append(tolerations, added)
+		return nil
+	})
+}
+
+// tolerationID identifies a toleration by its key, operator and value
+func tolerationID(toleration map[string]interface{}) string {
+	field := func(name string) string {
+		if v, ok := toleration[name]; ok && v != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return ""
+	}
+
+	operator := field("operator")
+	if operator == "" {
+		operator = "Equal"
+	}
+
+	return field("key") + "/" + operator + "/" + field("value")
+}
+
+// ResourceDefaults holds the compute resources given to the containers that don't specify them,
+// as kubernetes quantities such as "250m" or "128Mi". Empty values are not applied.
+type ResourceDefaults struct {
+	CPURequest    string
+	MemoryRequest string
+	CPULimit      string
+	MemoryLimit   string
+}
+
+// ApplyDefaultResources fills in the cpu and memory requests and limits of every container of the pods and workloads
+// of a manifest with defaults, only where they are missing. Values specified in the manifest are left intact.
+// A request is not added when the container sets the matching limit, kubernetes then defaults the request to the limit,
+// and no default is added that would make a request greater than its limit. It fails if a default is not a valid quantity.
+func ApplyDefaultResources(manifestYaml []byte, defaults ResourceDefaults) ([]byte, error) {
+	cpu, err := newResourceDefault(defaults.CPURequest, defaults.CPULimit)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid cpu default")
+	}
+
+	memory, err := newResourceDefault(defaults.MemoryRequest, defaults.MemoryLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid memory default")
+	}
+
+	return transformResources(manifestYaml, func(resource map[string]interface{}) error {
+		forEachContainer(resource, func(container map[string]interface{}) {
+			setDefaultResources(container, "cpu", cpu)
+			setDefaultResources(container, "memory", memory)
+		})
+		return nil
+	})
+}
+
+// resourceDefault is the default request and limit of a compute resource as written in the manifest,
+// along with their parsed quantities which are nil when not applied
+type resourceDefault struct {
+	request, limit                 string
+	requestQuantity, limitQuantity *resource.Quantity
+}
+
+func newResourceDefault(request, limit string) (resourceDefault, error) {
+	d := resourceDefault{request: request, limit: limit}
+
+	var err error
+	if d.requestQuantity, err = parseOptionalQuantity(request); err != nil {
+		return d, err
+	}
+
+	d.limitQuantity, err = parseOptionalQuantity(limit)
+	return d, err
+}
+
+func parseOptionalQuantity(value string) (*resource.Quantity, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return nil, errors.Wrapf(err, "'%s'", value)
+	}
+
+	return &q, nil
+}
+
+// setDefaultResources sets the missing request and limit of the name resource in container->resources,
+// keeping the request lower than or equal to the limit
+func setDefaultResources(container map[string]interface{}, name string, d resourceDefault) {
+	resources, ok := container["resources"].(map[string]interface{})
+	if !ok {
+		if container["resources"] != nil {
+			return
+		}
+		resources = make(map[string]interface{})
+	}
+
+	requests, ok := resources["requests"].(map[string]interface{})
+	if !ok {
+		if resources["requests"] != nil {
+			return
+		}
+		requests = make(map[string]interface{})
+	}
+
+	limits, ok := resources["limits"].(map[string]interface{})
+	if !ok {
+		if resources["limits"] != nil {
+			return
+		}
+		limits = make(map[string]interface{})
+	}
+
+	request, hasRequest := requests[name]
+	_, hasLimit := limits[name]
+
+	addLimit := !hasLimit && d.limitQuantity != nil
+	if addLimit && hasRequest {
+		// an explicit request that is not a valid quantity can't be compared, kubernetes rejects it anyway
+		q, err := resource.ParseQuantity(fmt.Sprintf("%v", request))
+		addLimit = err == nil && q.Cmp(*d.limitQuantity) <= 0
+	}
+
+	if addLimit {
+		limits[name] = d.limit
+	}
+
+	addRequest := !hasRequest && !hasLimit && d.requestQuantity != nil
+	if addRequest && addLimit {
+		addRequest = d.requestQuantity.Cmp(*d.limitQuantity) <= 0
+	}
+
+	if addRequest {
+		requests[name] = d.request
+	}
+
+	if len(requests) > 0 {
+		resources["requests"] = requests
+	}
+	if len(limits) > 0 {
+		resources["limits"] = limits
+	}
+	if len(resources) > 0 {
+		container["resources"] = resources
+	}
+}
+
+// podSpec returns the pod spec of a pod or of the pod template of a workload resource
+func podSpec(obj map[string]interface{}) (map[string]interface{}, bool) {
+	if kind, _ := obj["kind"].(string); strings.EqualFold(kind, "pod") {
+		return nestedMap(obj, "spec")
+	}
+
+	template, ok := podTemplate(obj)
+	if !ok {
+		return nil, false
+	}
+
+	return nestedMap(template, "spec")
+}
+
+// forEachContainer calls fn for every init container and container in the pod spec of a resource
+func forEachContainer(obj map[string]interface{}, fn func(container map[string]interface{})) {
+	spec, ok := podSpec(obj)
+	if !ok {
+		return
+	}
+
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, _ := spec[field].([]interface{})
+		for _, c := range containers {
+			if container, ok := c.(map[string]interface{}); ok {
+				fn(container)
+			}
+		}
+	}
+}
+
+// nestedMap returns the map found by following keys from obj
+func nestedMap(obj map[string]interface{}, keys ...string) (map[string]interface{}, bool) {
+	current := obj
+	for _, k := range keys {
+		next, ok := current[k].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current = next
+	}
+
+	return current, true
+}
@@ -0,0 +1,470 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SetReplicas(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 5
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+spec:
+  template: {}
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: StatefulSet
+    metadata:
+      name: db
+    spec:
+      serviceName: db
+  - apiVersion: batch/v1
+    kind: Job
+    metadata:
+      name: migrate
+    spec:
+      parallelism: 3
+kind: List
+`
+	expected := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+spec:
+  template: {}
+---
+apiVersion: v1
+items:
+  - apiVersion: apps/v1
+    kind: StatefulSet
+    metadata:
+      name: db
+    spec:
+      replicas: 1
+      serviceName: db
+  - apiVersion: batch/v1
+    kind: Job
+    metadata:
+      name: migrate
+    spec:
+      parallelism: 3
+kind: List
+`
+
+	result, err := SetReplicas([]byte(input), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	_, err = SetReplicas([]byte(input), -1)
+	assert.Error(t, err)
+}
+
+func Test_AddImagePullSecret(t *testing.T) {
+	input := `apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+spec:
+  containers:
+    - image: busybox
+      name: debug
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      imagePullSecrets:
+        - name: other
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          imagePullSecrets:
+            - name: registry
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+	expected := `apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+spec:
+  containers:
+    - image: busybox
+      name: debug
+  imagePullSecrets:
+    - name: registry
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      imagePullSecrets:
+        - name: other
+        - name: registry
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          imagePullSecrets:
+            - name: registry
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+
+	result, err := AddImagePullSecret([]byte(input), "registry")
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_ApplyDefaultResources(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - image: nginx:1.25
+          name: web
+          resources:
+            limits:
+              cpu: "2"
+              memory: 1Gi
+        - image: busybox
+          name: sidecar
+      initContainers:
+        - image: alpine
+          name: init
+          resources:
+            requests:
+              memory: 32Mi
+`
+	expected := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - image: nginx:1.25
+          name: web
+          resources:
+            limits:
+              cpu: "2"
+              memory: 1Gi
+        - image: busybox
+          name: sidecar
+          resources:
+            limits:
+              cpu: 500m
+              memory: 256Mi
+            requests:
+              cpu: 100m
+              memory: 128Mi
+      initContainers:
+        - image: alpine
+          name: init
+          resources:
+            limits:
+              cpu: 500m
+              memory: 256Mi
+            requests:
+              cpu: 100m
+              memory: 32Mi
+`
+
+	result, err := ApplyDefaultResources([]byte(input), ResourceDefaults{
+		CPURequest:    "100m",
+		MemoryRequest: "128Mi",
+		CPULimit:      "500m",
+		MemoryLimit:   "256Mi",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	result, err = ApplyDefaultResources([]byte(input), ResourceDefaults{})
+	assert.NoError(t, err)
+	assert.Equal(t, input, string(result))
+}
+
+func Test_ApplyDefaultResources_RequestsWithinLimits(t *testing.T) {
+	input := `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: limit-below-default-request
+      resources:
+        limits:
+          cpu: 50m
+    - name: request-above-default-limit
+      resources:
+        requests:
+          memory: 1Gi
+    - name: request-below-default-limit
+      resources:
+        requests:
+          memory: 64Mi
+`
+	expected := `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: limit-below-default-request
+      resources:
+        limits:
+          cpu: 50m
+          memory: 256Mi
+        requests:
+          memory: 128Mi
+    - name: request-above-default-limit
+      resources:
+        limits:
+          cpu: 500m
+        requests:
+          cpu: 100m
+          memory: 1Gi
+    - name: request-below-default-limit
+      resources:
+        limits:
+          cpu: 500m
+          memory: 256Mi
+        requests:
+          cpu: 100m
+          memory: 64Mi
+`
+
+	result, err := ApplyDefaultResources([]byte(input), ResourceDefaults{
+		CPURequest:    "100m",
+		MemoryRequest: "128Mi",
+		CPULimit:      "500m",
+		MemoryLimit:   "256Mi",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_ApplyDefaultResources_DefaultRequestAboveDefaultLimit(t *testing.T) {
+	input := `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+`
+	// the request is left for kubernetes to default to the limit
+	expected := `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      resources:
+        limits:
+          cpu: 500m
+`
+
+	result, err := ApplyDefaultResources([]byte(input), ResourceDefaults{CPURequest: "1", CPULimit: "500m"})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	_, err = ApplyDefaultResources([]byte(input), ResourceDefaults{MemoryLimit: "lots"})
+	assert.ErrorContains(t, err, "invalid memory default")
+}
+
+func Test_AddNodeSelector(t *testing.T) {
+	input := `apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+spec:
+  containers:
+    - image: busybox
+      name: debug
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      nodeSelector:
+        disktype: ssd
+        pool: default
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          restartPolicy: Never
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+	expected := `apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+spec:
+  containers:
+    - image: busybox
+      name: debug
+  nodeSelector:
+    pool: apps
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      nodeSelector:
+        disktype: ssd
+        pool: apps
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          nodeSelector:
+            pool: apps
+          restartPolicy: Never
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+
+	result, err := AddNodeSelector([]byte(input), map[string]string{"pool": "apps"})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+}
+
+func Test_AddToleration(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      tolerations:
+        - effect: NoSchedule
+          key: gpu
+          operator: Exists
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+spec:
+  template:
+    spec:
+      tolerations:
+        - effect: NoSchedule
+          key: pool
+          value: apps
+`
+	expected := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      tolerations:
+        - effect: NoSchedule
+          key: gpu
+          operator: Exists
+        - effect: NoSchedule
+          key: pool
+          operator: Equal
+          value: apps
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+spec:
+  template:
+    spec:
+      tolerations:
+        - effect: NoSchedule
+          key: pool
+          value: apps
+`
+
+	toleration := map[string]interface{}{"key": "pool", "operator": "Equal", "value": "apps", "effect": "NoSchedule"}
+
+	result, err := AddToleration([]byte(input), toleration)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(result))
+
+	_, err = AddToleration([]byte(input), nil)
+	assert.Error(t, err)
+}